@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/mcp/tools"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// CallbackName 审计回调的名称
+const CallbackName = "kom:audit"
+
+// Operations 审计的操作类型
+var Operations = []string{"create", "update", "patch", "delete", "exec", "stream-exec", "port-forward"}
+
+// Record 审计记录，每条记录按一行 JSON 写入 Sink
+type Record struct {
+	Time       time.Time   `json:"time"`
+	User       string      `json:"user,omitempty"`      // 从 Context 中按 AuthKey 获取的用户
+	Cluster    string      `json:"cluster"`             // 集群ID
+	Operation  string      `json:"operation"`           // create、update、patch、delete、exec、stream-exec、port-forward
+	Group      string      `json:"group,omitempty"`     // 资源组
+	Version    string      `json:"version,omitempty"`   // 资源版本
+	Kind       string      `json:"kind,omitempty"`      // 资源类型
+	Namespace  string      `json:"namespace,omitempty"` // 命名空间
+	Name       string      `json:"name,omitempty"`      // 资源名称
+	PatchType  string      `json:"patchType,omitempty"`
+	Patch      string      `json:"patch,omitempty"`     // Patch 内容，默认脱敏
+	Object     interface{} `json:"object,omitempty"`    // 创建、更新的对象，开启 WithObject 时记录，默认脱敏
+	Container  string      `json:"container,omitempty"` // 执行命令、端口转发的容器
+	Command    []string    `json:"command,omitempty"`   // 执行的命令及参数
+	LocalPort  string      `json:"localPort,omitempty"` // 端口转发的本地端口
+	PodPort    string      `json:"podPort,omitempty"`   // 端口转发的 Pod 端口
+	Force      bool        `json:"force,omitempty"`     // 是否强制删除
+	DurationMs int64       `json:"durationMs"`          // 耗时，毫秒
+	Success    bool        `json:"success"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Option 审计配置
+type Option func(*options)
+
+type options struct {
+	authKey  string
+	redact   bool
+	withBody bool
+}
+
+// WithAuthKey 指定从 Context 中获取用户的 key，默认使用 tools.SetAuthKey 设置的 key
+func WithAuthKey(key string) Option {
+	return func(o *options) {
+		o.authKey = key
+	}
+}
+
+// WithoutRedact 关闭脱敏，记录 Secret 的原始内容
+func WithoutRedact() Option {
+	return func(o *options) {
+		o.redact = false
+	}
+}
+
+// WithObject 记录创建、更新的对象内容
+func WithObject() Option {
+	return func(o *options) {
+		o.withBody = true
+	}
+}
+
+// Register 为集群注册审计回调
+// 在 create、update、patch、delete、exec、stream-exec、port-forward 操作完成后写入审计记录，操作失败时同样记录。
+// 写入失败只输出日志，不影响操作结果。重复注册时替换之前的配置。
+//
+//	sink, _ := audit.NewFileSink("/var/log/kom/audit.log")
+//	_ = audit.Register(kom.DefaultCluster(), sink)
+func Register(k *kom.Kubectl, sink Sink, opts ...Option) error {
+	if sink == nil {
+		return fmt.Errorf("audit sink 不能为空")
+	}
+	o := &options{redact: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	fn := func(tx *kom.Kubectl) error {
+		record := newRecord(tx, o)
+		if record == nil {
+			return nil
+		}
+		if err := sink.Write(record); err != nil {
+			klog.Errorf("audit write %s %s/%s error %v", record.Operation, record.Namespace, record.Name, err)
+		}
+		return nil
+	}
+	for _, name := range Operations {
+		p := k.Callback().Processor(name)
+		var err error
+		if p.Get(CallbackName) != nil {
+			err = p.Replace(CallbackName, fn)
+		} else {
+			err = p.After("*").Register(CallbackName, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unregister 移除集群的审计回调
+func Unregister(k *kom.Kubectl) {
+	for _, name := range Operations {
+		if p := k.Callback().Processor(name); p.Get(CallbackName) != nil {
+			_ = p.Remove(CallbackName)
+		}
+	}
+}
+
+// newRecord 根据当前操作生成审计记录
+func newRecord(k *kom.Kubectl, o *options) *Record {
+	stmt := k.Statement
+	op := stmt.Operation
+	if op == nil {
+		return nil
+	}
+	record := &Record{
+		Time:       op.StartTime,
+		User:       tools.ContextUser(stmt.Context, o.authKey),
+		Cluster:    k.ID,
+		Operation:  op.Type,
+		Group:      stmt.GVK.Group,
+		Version:    stmt.GVK.Version,
+		Kind:       stmt.GVK.Kind,
+		Namespace:  stmt.Namespace,
+		Name:       stmt.Name,
+		DurationMs: op.Duration().Milliseconds(),
+		Success:    op.Error == nil,
+	}
+	if op.Error != nil {
+		record.Error = op.Error.Error()
+	}
+	secret := isSecret(stmt)
+
+	switch op.Type {
+	case "create", "update":
+		obj := toUnstructured(stmt.Dest)
+		if obj != nil {
+			if accessor, err := meta.Accessor(obj); err == nil {
+				if record.Name == "" {
+					record.Name = accessor.GetName()
+				}
+				if record.Namespace == "" {
+					record.Namespace = accessor.GetNamespace()
+				}
+			}
+			if record.Kind == "" {
+				gvk := obj.GroupVersionKind()
+				record.Group, record.Version, record.Kind = gvk.Group, gvk.Version, gvk.Kind
+			}
+			if _, ok := stmt.Dest.(*corev1.Secret); ok || (obj.GetAPIVersion() == "v1" && obj.GetKind() == "Secret") {
+				secret = true
+			}
+			if o.withBody {
+				obj = obj.DeepCopy()
+				unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+				if o.redact && secret {
+					redactObject(obj.Object)
+				}
+				record.Object = obj.Object
+			}
+		}
+	case "patch":
+		record.PatchType = string(stmt.PatchType)
+		record.Patch = stmt.PatchData
+		if o.redact && secret {
+			record.Patch = redactPatch(stmt.PatchData)
+		}
+	case "delete":
+		record.Force = stmt.ForceDelete
+	case "exec", "stream-exec":
+		record.Container = stmt.ContainerName
+		record.Command = append([]string{stmt.Command}, stmt.Args...)
+	case "port-forward":
+		record.Container = stmt.ContainerName
+		record.LocalPort = stmt.PortForwardLocalPort
+		record.PodPort = stmt.PortForwardPodPort
+	}
+	if record.Namespace == "" && stmt.Namespaced {
+		record.Namespace = metav1.NamespaceDefault
+	}
+	if record.Kind == "" && (op.Type == "exec" || op.Type == "stream-exec" || op.Type == "port-forward") {
+		record.Version, record.Kind = "v1", "Pod"
+	}
+	return record
+}
+
+func toUnstructured(dest interface{}) *unstructured.Unstructured {
+	switch v := dest.(type) {
+	case nil:
+		return nil
+	case *unstructured.Unstructured:
+		return v
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dest)
+	if err != nil {
+		return nil
+	}
+	return &unstructured.Unstructured{Object: data}
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/weibaohui/kom/kom"
+)
+
+// redacted 脱敏后的值
+const redacted = "******"
+
+// lastAppliedAnnotation kubectl apply 记录的上次配置，Secret 中包含原始数据
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+func isSecret(stmt *kom.Statement) bool {
+	return (stmt.GVK.Group == "" && stmt.GVK.Kind == "Secret") || (stmt.GVR.Group == "" && stmt.GVR.Resource == "secrets")
+}
+
+// redactObject 将 Secret 的 data、stringData 的值及 last-applied-configuration 注解替换为 ******
+func redactObject(obj map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		if values, ok := obj[field].(map[string]interface{}); ok {
+			for key := range values {
+				values[key] = redacted
+			}
+		}
+	}
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			if _, ok := annotations[lastAppliedAnnotation]; ok {
+				annotations[lastAppliedAnnotation] = redacted
+			}
+		}
+	}
+}
+
+// redactPatch Secret 的 Patch 脱敏
+// merge patch、strategic merge patch 按对象脱敏，json patch 脱敏 data、stringData 路径下的值，无法解析时整体脱敏
+func redactPatch(patch string) string {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(patch), &obj); err == nil {
+		redactObject(obj)
+		data, _ := json.Marshal(obj)
+		return string(data)
+	}
+	var ops []map[string]interface{}
+	if err := json.Unmarshal([]byte(patch), &ops); err == nil {
+		for _, op := range ops {
+			path, _ := op["path"].(string)
+			if _, ok := op["value"]; !ok {
+				continue
+			}
+			switch {
+			case path == "/data" || path == "/stringData",
+				strings.HasPrefix(path, "/data/"), strings.HasPrefix(path, "/stringData/"):
+				op["value"] = redacted
+			case path == "/metadata/annotations":
+				if annotations, ok := op["value"].(map[string]interface{}); ok {
+					if _, ok := annotations[lastAppliedAnnotation]; ok {
+						annotations[lastAppliedAnnotation] = redacted
+					}
+				}
+			case path == "/metadata/annotations/"+strings.ReplaceAll(lastAppliedAnnotation, "/", "~1"):
+				op["value"] = redacted
+			case path == "" || path == "/":
+				if value, ok := op["value"].(map[string]interface{}); ok {
+					redactObject(value)
+				} else {
+					op["value"] = redacted
+				}
+			}
+		}
+		data, _ := json.Marshal(ops)
+		return string(data)
+	}
+	return redacted
+}
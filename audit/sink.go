@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink 审计记录的输出
+type Sink interface {
+	Write(record *Record) error
+}
+
+// SinkFunc 使用函数作为 Sink
+type SinkFunc func(record *Record) error
+
+func (f SinkFunc) Write(record *Record) error {
+	return f(record)
+}
+
+// WriterSink 按行写入 JSON，并发安全
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink 写入 io.Writer 的 Sink，如 os.Stdout
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
+
+// Close 关闭底层的 Writer，Writer 未实现 io.Closer 时不做处理
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewFileSink 追加写入文件的 Sink，文件不存在时创建
+func NewFileSink(path string) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f), nil
+}
+
+// WebhookSink 将每条审计记录以 JSON POST 到指定地址
+type WebhookSink struct {
+	URL     string
+	Header  http.Header // 附加的请求头，如 Authorization
+	Timeout time.Duration
+	Client  *http.Client // 为空时使用 http.DefaultClient
+}
+
+// NewWebhookSink 创建 Webhook Sink，超时时间默认 3 秒
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, Timeout: 3 * time.Second}
+}
+
+// Write 同步发送记录，返回非 2xx 状态码时返回错误
+func (s *WebhookSink) Write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.Header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook %s returned %s", s.URL, resp.Status)
+	}
+	return nil
+}
@@ -14,7 +14,8 @@ func init() {
 	RegisterInit()
 }
 
-// RegisterDefaultCallbacks 为指定的集群实例注册一组默认的 Kubernetes 操作回调，包括资源的查询、列表、监控、创建、更新、补丁、删除、命令执行、流式命令执行、端口转发、日志获取和资源描述等操作。
+// RegisterDefaultCallbacks 为指定的集群实例注册一组默认的 Kubernetes 操作回调，包括资源的查询、列表、监控、持续查询、创建、更新、补丁、删除、命令执行、流式命令执行、端口转发、日志获取和资源描述等操作。
+// 离线集群只保留查询类回调，其他操作返回错误。
 // 返回一个空的清理函数。
 func RegisterDefaultCallbacks(c *kom.ClusterInst) func() {
 
@@ -32,6 +33,9 @@ func RegisterDefaultCallbacks(c *kom.ClusterInst) func() {
 	watchCallback := k.Callback().Watch()
 	_ = watchCallback.Register("kom:watch", Watch)
 
+	watchQueryCallback := k.Callback().WatchQuery()
+	_ = watchQueryCallback.Register("kom:watch-query", WatchQuery)
+
 	createCallback := k.Callback().Create()
 	_ = createCallback.Register("kom:create", Create)
 
@@ -62,5 +66,20 @@ func RegisterDefaultCallbacks(c *kom.ClusterInst) func() {
 	docCallback := k.Callback().Doc()
 	_ = docCallback.Register("kom:doc", Doc)
 
+	if c.OfflineSource() != nil {
+		// 离线集群只支持查询，其他操作直接返回错误
+		_ = watchCallback.Replace("kom:watch", OfflineUnsupported)
+		_ = watchQueryCallback.Replace("kom:watch-query", OfflineUnsupported)
+		_ = createCallback.Replace("kom:create", OfflineUnsupported)
+		_ = updateCallback.Replace("kom:update", OfflineUnsupported)
+		_ = patchCallback.Replace("kom:patch", OfflineUnsupported)
+		_ = deleteCallback.Replace("kom:delete", OfflineUnsupported)
+		_ = execCallback.Replace("kom:pod:exec", OfflineUnsupported)
+		_ = streamExecCallback.Replace("kom:pod:stream:exec", OfflineUnsupported)
+		_ = portForwardCallback.Replace("kom:pod:port:forward", OfflineUnsupported)
+		_ = logsCallback.Replace("kom:pod:logs", OfflineUnsupported)
+		_ = describeCallback.Replace("kom:describe", OfflineUnsupported)
+	}
+
 	return nil
 }
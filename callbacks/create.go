@@ -38,6 +38,8 @@ func Create(k *kom.Kubectl) error {
 	if err != nil {
 		return err
 	}
+	// 清除该资源的 Get、List 缓存
+	k.InvalidateCache(gvr, res.GetNamespace(), res.GetName())
 	stmt.RowsAffected = 1
 	if stmt.RemoveManagedFields {
 		utils.RemoveManagedFields(res)
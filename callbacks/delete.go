@@ -43,6 +43,11 @@ func Delete(k *kom.Kubectl) error {
 	if err != nil {
 		return err
 	}
+	// 清除该资源的 Get、List 缓存
+	if !namespaced {
+		ns = ""
+	}
+	k.InvalidateCache(gvr, ns, name)
 	stmt.RowsAffected = 1
 	return nil
 }
@@ -29,7 +29,7 @@ func Doc(k *kom.Kubectl) error {
 		return fmt.Errorf("请确保dest 是一个指向字节切片的指针。定义var s []byte 使用&s")
 	}
 
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s", gvk.Group, gvk.Version, gvk.Kind, field)
+	cacheKey := fmt.Sprintf("doc/%s/%s/%s/%s", gvk.Group, gvk.Version, gvk.Kind, field)
 	result, err := utils.GetOrSetCache(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (result string, err error) {
 		apiDoc := doc.DocField{
 			Kind: gvk.Kind,
@@ -1,6 +1,7 @@
 package callbacks
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/weibaohui/kom/kom"
@@ -28,12 +29,26 @@ func Get(k *kom.Kubectl) error {
 		return err
 	}
 
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s/%s", ns, name, gvr.Group, gvr.Resource, gvr.Version)
-	res, err := utils.GetOrSetCache(stmt.Kubectl.ClusterCache(), cacheKey, stmt.CacheTTL, func() (ret *unstructured.Unstructured, err error) {
-		if namespaced {
-			if ns == "" {
-				ns = metav1.NamespaceDefault
+	cacheKey := fmt.Sprintf("%sget/%s/%s", kom.CacheKeyPrefix(gvr), ns, name)
+	res, err := utils.GetOrSetCacheWithContext(ctx, stmt.Kubectl.ClusterCache(), cacheKey, stmt.CacheTTL, func(ctx context.Context) (ret *unstructured.Unstructured, err error) {
+		if source := k.OfflineSource(); source != nil {
+			// 离线集群，从内存中的表获取
+			return getOfflineItem(k, source)
+		}
+		if namespaced && ns == "" {
+			ns = metav1.NamespaceDefault
+		}
+		if ic := k.InformerCache(); ic != nil && ic.Has(gvr) {
+			// 开启了 informer 缓存，从索引中获取，集群级资源不使用命名空间
+			icNamespace := ns
+			if !namespaced {
+				icNamespace = ""
 			}
+			if item, ok, err := ic.Get(ctx, gvr, icNamespace, name); ok {
+				return item, err
+			}
+		}
+		if namespaced {
 			ret, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
 		} else {
 			ret, err = stmt.Kubectl.DynamicClient().Resource(gvr).Get(ctx, name, metav1.GetOptions{})
@@ -43,6 +58,8 @@ func Get(k *kom.Kubectl) error {
 	if err != nil {
 		return err
 	}
+	// 记录缓存键，资源写入后清除
+	k.TrackCacheKey(gvr, res.GetNamespace(), name, cacheKey, stmt.CacheTTL)
 
 	stmt.RowsAffected = 1
 	if stmt.RemoveManagedFields {
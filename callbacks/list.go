@@ -1,11 +1,12 @@
 package callbacks
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
-	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/stream"
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/utils"
@@ -26,23 +27,6 @@ func ConvertUnstructuredItems(src *unstructured.UnstructuredList) []*unstructure
 func List(k *kom.Kubectl) error {
 
 	stmt := k.Statement
-	gvr := stmt.GVR
-	namespaced := stmt.Namespaced
-	ns := stmt.Namespace
-	ctx := stmt.Context
-	conditions := stmt.Filter.Conditions
-	namespaceList := stmt.NamespaceList
-
-	opts := stmt.ListOptions
-	listOptions := metav1.ListOptions{}
-	listOptionsMD5 := "" // cache key值
-	if len(opts) > 0 {
-		listOptions = opts[0]
-
-		// 将listOptions序列化为JSON字符串，并取MD5摘要，加入cacheKey
-		listOptionsStr := utils.ToJSON(listOptions)
-		listOptionsMD5 = utils.MD5Hash(listOptionsStr)
-	}
 
 	// 使用反射获取 dest 的值
 	destValue := reflect.ValueOf(stmt.Dest)
@@ -55,44 +39,58 @@ func List(k *kom.Kubectl) error {
 	// 获取切片的元素类型
 	elemType := destValue.Elem().Type().Elem()
 
-	cacheKey := fmt.Sprintf("%s/%s/%s/%s/%s", ns, gvr.Group, gvr.Resource, gvr.Version, listOptionsMD5)
-	list, err := utils.GetOrSetCache(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (list *unstructured.UnstructuredList, err error) {
-		// TODO 获取列表改为使用Option,解决大数据量获取问题。
-		if namespaced {
-			if stmt.AllNamespace || len(namespaceList) > 1 {
-				// 全部命名空间 或者  传入多个命名空间
-				// client-go 不支持跨命名空间查询，就全部查出来，后面再过滤
-				ns = metav1.NamespaceAll
-				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
-			} else {
-				// 不是全部，也没有传多个命名空间
-				if ns == "" {
-					ns = metav1.NamespaceDefault
-				}
-				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+	var err error
+	var fetched int
+	var result []*unstructured.Unstructured
+	joined := len(stmt.Filter.Joins) > 0
+	// join 查询，结果为各表对象按表别名组装的记录
+	if joined && !isRowType(elemType) {
+		return fmt.Errorf("join 查询请使用 []kom.Row 或 []map[string]interface{} 承载结果")
+	}
+	if stmt.Filter.IsMultiCluster() {
+		// 跨集群查询，各集群分别执行子查询、join 及过滤后合并
+		if result, fetched, err = listClusters(k); err != nil {
+			return err
+		}
+	} else {
+		// 先执行 where 中的子查询，求值结果只用于本次查询
+		filter, err := k.ResolveSubqueries()
+		if err != nil {
+			return err
+		}
+		original := stmt.Filter
+		stmt.Filter = filter
+		defer func() { stmt.Filter = original }()
+
+		// 谓词下推，能由 API Server 处理的条件转换为命名空间、标签选择器、字段选择器
+		plan := k.PushdownPlan()
+		klog.V(6).Infof("pushdown plan:\n%s", plan.Explain())
+
+		items, err := listItems(k, plan)
+		if err != nil {
+			return err
+		}
+		fetched = len(items)
+
+		if joined {
+			if items, err = executeJoin(k, items); err != nil {
+				return err
 			}
-		} else {
-			// 集群级查询，不需要namespace
-			list, err = stmt.Kubectl.DynamicClient().Resource(gvr).List(ctx, listOptions)
 		}
-		return
-	})
-	if err != nil {
-		return err
-	}
-	if list == nil {
-		// 为空直接返回
-		return fmt.Errorf("list is nil")
-	}
-	if list.Items == nil {
-		// 为空直接返回
-		return fmt.Errorf("list Items is nil")
+
+		// 对结果进行过滤，执行未下推的 where 条件
+		result = executeFilter(items, &kom.Filter{Expr: plan.Residual})
 	}
 
-	items := ConvertUnstructuredItems(list)
+	// 分组聚合，每组生成一条结果行
+	aggregated := stmt.Filter.IsAggregate()
+	if aggregated {
+		if !isRowType(elemType) {
+			return fmt.Errorf("分组聚合查询请使用 []kom.Row 或 []map[string]interface{} 承载结果")
+		}
+		result = executeAggregate(result, &stmt.Filter)
+	}
 
-	// 对结果进行过滤，执行where 条件
-	result := executeFilter(items, conditions)
 	if stmt.TotalCount != nil {
 		*stmt.TotalCount = int64(len(result))
 	}
@@ -100,8 +98,13 @@ func List(k *kom.Kubectl) error {
 	if stmt.Filter.Order != "" {
 		// 对结果执行OrderBy
 		klog.V(6).Infof("order by = %s", stmt.Filter.Order)
-		executeOrderBy(result, stmt.Filter.Order)
-	} else {
+		var projection []*kom.Column
+		if !aggregated {
+			// 分组聚合的结果行以列名为键，可直接按别名排序
+			projection = stmt.Filter.Projection
+		}
+		executeOrderBy(result, stmt.Filter.Order, projection)
+	} else if !aggregated && !joined {
 		// 默认按创建时间倒序
 		utils.SortByCreationTime(result)
 	}
@@ -118,6 +121,17 @@ func List(k *kom.Kubectl) error {
 	}
 
 	for _, item := range streamTmp.ToSlice() {
+		if aggregated {
+			row := reflect.ValueOf(aggregateRow(item, stmt.Filter.Projection)).Convert(elemType)
+			destValue.Elem().Set(reflect.Append(destValue.Elem(), row))
+			continue
+		}
+		if isRowType(elemType) {
+			// 结果行，只保留查询列，不再转换为完整对象
+			row := reflect.ValueOf(projectRow(item, stmt.Filter.Projection, stmt.RemoveManagedFields)).Convert(elemType)
+			destValue.Elem().Set(reflect.Append(destValue.Elem(), row))
+			continue
+		}
 
 		obj := item.DeepCopy()
 		if stmt.RemoveManagedFields {
@@ -131,7 +145,7 @@ func List(k *kom.Kubectl) error {
 		destValue.Elem().Set(reflect.Append(destValue.Elem(), newElemPtr.Elem()))
 
 	}
-	stmt.RowsAffected = int64(len(list.Items))
+	stmt.RowsAffected = int64(fetched)
 
 	if err != nil {
 		return err
@@ -139,79 +153,217 @@ func List(k *kom.Kubectl) error {
 	return nil
 }
 
-func executeOrderBy(result []*unstructured.Unstructured, order string) {
-	// order by `metadata.name` asc, `metadata.host` asc
-	// todo 目前只实现了单一字段的排序，还没有搞定多个字段的排序
-	order = strings.TrimPrefix(strings.TrimSpace(order), "order by")
-	order = strings.TrimSpace(order)
-	orders := strings.Split(order, ",")
-	for _, ord := range orders {
-		var field string
-		var desc bool
-		// 判断排序方向
-		if strings.Contains(ord, "desc") {
-			desc = true
-			field = strings.ReplaceAll(ord, "desc", "")
-		} else {
-			field = strings.ReplaceAll(ord, "asc", "")
-		}
-		field = strings.TrimSpace(field)
-		field = strings.TrimSpace(utils.TrimQuotes(field))
-		klog.V(6).Infof("Sorting by field: %s, Desc: %v", field, desc)
-
-		slice.SortBy(result, func(a, b *unstructured.Unstructured) bool {
-			// 获取字段值
-			aFieldValues, found, err := getNestedFieldAsString(a.Object, field)
-			if err != nil || !found {
-				return false
+// listItems 按照下推计划获取资源列表
+func listItems(k *kom.Kubectl, plan *kom.PushdownPlan) ([]*unstructured.Unstructured, error) {
+	if source := k.OfflineSource(); source != nil {
+		// 离线集群，从内存中的表获取
+		return listOfflineItems(k, source, plan)
+	}
+	if ic := k.InformerCache(); ic != nil {
+		// 开启了 informer 缓存，从索引中获取
+		if items, ok, err := listInformerItems(k, ic, plan); ok || err != nil {
+			return items, err
+		}
+	}
+	stmt := k.Statement
+	gvr := stmt.GVR
+	namespaced := stmt.Namespaced
+	ns := stmt.Namespace
+	ctx := stmt.Context
+	namespaceList := stmt.NamespaceList
+
+	opts := stmt.ListOptions
+	listOptions := metav1.ListOptions{}
+	if len(opts) > 0 {
+		listOptions = opts[0]
+	}
+	listOptions = plan.ApplyTo(listOptions)
+	listOptionsMD5 := "" // cache key值
+	if len(opts) > 0 || plan.LabelSelector != "" || plan.FieldSelector != "" {
+		// 将listOptions序列化为JSON字符串，并取MD5摘要，加入cacheKey
+		listOptionsStr := utils.ToJSON(listOptions)
+		listOptionsMD5 = utils.MD5Hash(listOptionsStr)
+	}
+
+	fetch := func(ns string) (*unstructured.UnstructuredList, error) {
+		cacheKey := fmt.Sprintf("%slist/%s/%s", kom.CacheKeyPrefix(gvr), ns, listOptionsMD5)
+		trackNs := ns
+		if !namespaced {
+			trackNs = ""
+		}
+		// 记录缓存键，该命名空间下的资源写入后清除
+		defer k.TrackCacheKey(gvr, trackNs, "", cacheKey, stmt.CacheTTL)
+		return utils.GetOrSetCacheWithContext(ctx, stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func(ctx context.Context) (list *unstructured.UnstructuredList, err error) {
+			// TODO 获取列表改为使用Option,解决大数据量获取问题。
+			if namespaced {
+				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, listOptions)
+			} else {
+				// 集群级查询，不需要namespace
+				list, err = stmt.Kubectl.DynamicClient().Resource(gvr).List(ctx, listOptions)
 			}
-			bFieldValues, found, err := getNestedFieldAsString(b.Object, field)
-			if err != nil || !found {
-				return false
+			return
+		})
+	}
+
+	if plan.ByNamespace {
+		// 命名空间条件已下推，按命名空间分别查询
+		var items []*unstructured.Unstructured
+		for _, n := range plan.Namespaces {
+			list, err := fetch(n)
+			if err != nil {
+				return nil, err
 			}
+			items = append(items, ConvertUnstructuredItems(list)...)
+		}
+		return items, nil
+	}
+
+	if namespaced {
+		if stmt.AllNamespace || len(namespaceList) > 1 {
+			// 全部命名空间 或者  传入多个命名空间
+			// client-go 不支持跨命名空间查询，就全部查出来，后面再过滤
+			ns = metav1.NamespaceAll
+		} else if ns == "" {
+			// 不是全部，也没有传多个命名空间
+			ns = metav1.NamespaceDefault
+		}
+	}
+	list, err := fetch(ns)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		// 为空直接返回
+		return nil, fmt.Errorf("list is nil")
+	}
+	if list.Items == nil {
+		// 为空直接返回
+		return nil, fmt.Errorf("list Items is nil")
+	}
+	return ConvertUnstructuredItems(list), nil
+}
 
-			// order by 必须把数组变为单一的值
-			if len(aFieldValues) > 1 || len(bFieldValues) > 1 {
-				return false
+// orderField 排序字段
+type orderField struct {
+	field      string
+	desc       bool
+	nullsFirst bool
+}
+
+// parseOrderFields 解析排序字符串
+// order by `metadata.name` asc, status.phase desc nulls last
+// 未指定 nulls first/last 时，asc 排序字段不存在的排在最后，desc 排在最前
+// 排序字段为查询列的别名时，按该列的字段路径排序
+func parseOrderFields(order string, projection []*kom.Column) []orderField {
+	order = strings.TrimSpace(order)
+	if len(order) >= 8 && strings.EqualFold(order[:8], "order by") {
+		order = order[8:]
+	}
+	var fields []orderField
+	for _, ord := range strings.Split(order, ",") {
+		tokens := strings.Fields(ord)
+		if len(tokens) == 0 {
+			continue
+		}
+		f := orderField{}
+		nullsSet := false
+		if n := len(tokens); n >= 3 && strings.EqualFold(tokens[n-2], "nulls") {
+			f.nullsFirst = strings.EqualFold(tokens[n-1], "first")
+			nullsSet = true
+			tokens = tokens[:n-2]
+		}
+		if n := len(tokens); n >= 2 {
+			switch strings.ToLower(tokens[n-1]) {
+			case "desc":
+				f.desc = true
+				tokens = tokens[:n-1]
+			case "asc":
+				tokens = tokens[:n-1]
 			}
-			if len(aFieldValues) == 0 || len(bFieldValues) == 0 {
-				return false
+		}
+		if !nullsSet {
+			f.nullsFirst = f.desc
+		}
+		f.field = strings.TrimSpace(utils.TrimQuotes(strings.Join(tokens, " ")))
+		for _, c := range projection {
+			if c.Alias != "" && c.Func == "" && strings.EqualFold(c.Alias, f.field) {
+				f.field = c.Field
+				break
 			}
-			aFieldValue := aFieldValues[0]
-			bFieldValue := bFieldValues[0]
-
-			t, va := utils.DetectType(aFieldValue)
-			_, vb := utils.DetectType(bFieldValue)
-
-			switch t {
-			case utils.TypeString:
-				if desc {
-					return va.(string) > vb.(string)
-				}
-				return va.(string) < vb.(string)
-			case utils.TypeNumber:
-				if desc {
-					return va.(float64) > vb.(float64)
-				}
-				return va.(float64) < vb.(float64)
-			case utils.TypeTime:
-				tva, err := utils.ParseTime(fmt.Sprintf("%s", va))
-				if err != nil {
-					return false
-				}
-				tvb, err := utils.ParseTime(fmt.Sprintf("%s", vb))
-				if err != nil {
-					return false
-				}
-				if desc {
-					return tva.After(tvb)
-				}
-				return tva.Before(tvb)
-			default:
-				return false
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// executeOrderBy 按多个字段排序
+// 依次比较每个排序字段，前一个字段相同时才比较下一个字段。
+// 按数字、时间、Quantity、字符串的顺序识别值类型进行比较，字段为数组时取第一个值。
+// 所有排序字段都相同时，按命名空间、名称排序，保证分页结果稳定
+// projection 为查询列，用于将别名解析为字段路径
+func executeOrderBy(result []*unstructured.Unstructured, order string, projection []*kom.Column) {
+	fields := parseOrderFields(order, projection)
+	klog.V(6).Infof("Sorting by fields: %v", fields)
+
+	// 预先取出排序字段的值，避免排序时重复解析
+	type sortItem struct {
+		item   *unstructured.Unstructured
+		values []*string
+	}
+	items := make([]sortItem, len(result))
+	for i, item := range result {
+		values := make([]*string, len(fields))
+		for j, f := range fields {
+			fieldValues, found, err := getNestedFieldAsString(item.Object, f.field)
+			if err == nil && found && len(fieldValues) > 0 {
+				values[j] = &fieldValues[0]
 			}
-		})
+		}
+		items[i] = sortItem{item: item, values: values}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for k, f := range fields {
+			c := compareOrderValue(items[i].values[k], items[j].values[k], f)
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return compareNamespacedName(items[i].item, items[j].item) < 0
+	})
+
+	for i := range items {
+		result[i] = items[i].item
+	}
+}
 
+// compareOrderValue 比较单个排序字段的值，处理值不存在（NULL）的情况
+func compareOrderValue(a, b *string, f orderField) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		if f.nullsFirst {
+			return -1
+		}
+		return 1
+	case b == nil:
+		if f.nullsFirst {
+			return 1
+		}
+		return -1
 	}
+	c := compareFieldValues(*a, *b)
+	if f.desc {
+		return -c
+	}
+	return c
+}
 
+// compareNamespacedName 按命名空间、名称比较
+func compareNamespacedName(a, b *unstructured.Unstructured) int {
+	if c := strings.Compare(a.GetNamespace(), b.GetNamespace()); c != 0 {
+		return c
+	}
+	return strings.Compare(a.GetName(), b.GetName())
 }
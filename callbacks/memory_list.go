@@ -0,0 +1,126 @@
+package callbacks
+
+import (
+	"github.com/weibaohui/kom/kom"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// selectableFields 内存中过滤时字段选择器支持的字段，与谓词下推生成的字段选择器一致
+var selectableFields = []string{"metadata.name", "metadata.namespace", "spec.nodeName", "status.phase"}
+
+// memorySelector 在内存中执行 ListOptions 的标签选择器、字段选择器
+// 用于离线数据源、informer 缓存等不经过 API Server 的列表查询
+type memorySelector struct {
+	labels labels.Selector
+	fields fields.Selector
+}
+
+func newMemorySelector(opts metav1.ListOptions) (*memorySelector, error) {
+	labelSelector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	return &memorySelector{labels: labelSelector, fields: fieldSelector}, nil
+}
+
+// supported 字段选择器中的字段是否都能在内存中计算
+func (s *memorySelector) supported() bool {
+	for _, r := range s.fields.Requirements() {
+		supported := false
+		for _, f := range selectableFields {
+			if r.Field == f {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *memorySelector) matches(item *unstructured.Unstructured) bool {
+	if !s.labels.Matches(labels.Set(item.GetLabels())) {
+		return false
+	}
+	if s.fields.Empty() {
+		return true
+	}
+	set := fields.Set{
+		"metadata.name":      item.GetName(),
+		"metadata.namespace": item.GetNamespace(),
+	}
+	for _, path := range selectableFields[2:] {
+		if v, found, _ := getNestedFieldAsString(item.Object, path); found && len(v) > 0 {
+			set[path] = v[0]
+		}
+	}
+	return s.fields.Matches(set)
+}
+
+// firstListOptions 获取调用方传入的 ListOptions
+func firstListOptions(stmt *kom.Statement) metav1.ListOptions {
+	if len(stmt.ListOptions) > 0 {
+		return stmt.ListOptions[0]
+	}
+	return metav1.ListOptions{}
+}
+
+// listNamespaces 需要查询的命名空间，与请求 API Server 时的规则一致
+// 命名空间条件已下推时按下推的命名空间查询，全部命名空间或传入多个命名空间时返回 all
+func listNamespaces(stmt *kom.Statement, plan *kom.PushdownPlan) (namespaces []string, all bool) {
+	switch {
+	case !stmt.Namespaced:
+		return nil, true
+	case plan.ByNamespace:
+		return plan.Namespaces, false
+	case stmt.AllNamespace || len(stmt.NamespaceList) > 1:
+		return nil, true
+	case stmt.Namespace != "":
+		return []string{stmt.Namespace}, false
+	default:
+		return []string{metav1.NamespaceDefault}, false
+	}
+}
+
+// listInformerItems 从 informer 缓存获取资源列表
+// 第二个返回值为 false 表示缓存无法提供，需要请求 API Server
+func listInformerItems(k *kom.Kubectl, ic *kom.InformerCache, plan *kom.PushdownPlan) ([]*unstructured.Unstructured, bool, error) {
+	stmt := k.Statement
+	listOptions := plan.ApplyTo(firstListOptions(stmt))
+	if !ic.CanServe(stmt.GVR, listOptions) {
+		return nil, false, nil
+	}
+	selector, err := newMemorySelector(listOptions)
+	if err != nil {
+		return nil, false, err
+	}
+	if !selector.supported() {
+		return nil, false, nil
+	}
+	namespaces, all := listNamespaces(stmt, plan)
+	if all {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	var items []*unstructured.Unstructured
+	for _, ns := range namespaces {
+		list, ok := ic.List(stmt.Context, stmt.GVR, ns, listOptions.ResourceVersion)
+		if !ok {
+			return nil, false, nil
+		}
+		for _, item := range list {
+			if selector.matches(item) {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, true, nil
+}
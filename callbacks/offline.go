@@ -0,0 +1,57 @@
+package callbacks
+
+import (
+	"fmt"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/weibaohui/kom/kom"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// OfflineUnsupported 离线集群不支持的操作
+func OfflineUnsupported(k *kom.Kubectl) error {
+	return fmt.Errorf("离线集群 %s 只支持查询操作", k.ID)
+}
+
+// listOfflineItems 从离线数据源获取资源列表
+// 按照命名空间范围及 ListOptions、下推计划中的标签选择器、字段选择器过滤，与 API Server 的行为保持一致
+func listOfflineItems(k *kom.Kubectl, source *kom.OfflineSource, plan *kom.PushdownPlan) ([]*unstructured.Unstructured, error) {
+	stmt := k.Statement
+	selector, err := newMemorySelector(plan.ApplyTo(firstListOptions(stmt)))
+	if err != nil {
+		return nil, err
+	}
+	namespaces, all := listNamespaces(stmt, plan)
+	var items []*unstructured.Unstructured
+	for _, item := range source.Items(stmt.GVK) {
+		if !all && !slice.Contain(namespaces, item.GetNamespace()) {
+			continue
+		}
+		if !selector.matches(item) {
+			continue
+		}
+		// 返回副本，避免后续处理修改数据源
+		items = append(items, item.DeepCopy())
+	}
+	return items, nil
+}
+
+// getOfflineItem 从离线数据源获取单个资源
+func getOfflineItem(k *kom.Kubectl, source *kom.OfflineSource) (*unstructured.Unstructured, error) {
+	stmt := k.Statement
+	ns := ""
+	if stmt.Namespaced {
+		ns = stmt.Namespace
+		if ns == "" {
+			ns = metav1.NamespaceDefault
+		}
+	}
+	for _, item := range source.Items(stmt.GVK) {
+		if item.GetNamespace() == ns && item.GetName() == stmt.Name {
+			return item.DeepCopy(), nil
+		}
+	}
+	return nil, apierrors.NewNotFound(stmt.GVR.GroupResource(), stmt.Name)
+}
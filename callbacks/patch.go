@@ -38,6 +38,8 @@ func Patch(k *kom.Kubectl) error {
 	if err != nil {
 		return err
 	}
+	// 清除该资源的 Get、List 缓存
+	k.InvalidateCache(gvr, res.GetNamespace(), name)
 
 	stmt.RowsAffected = 1
 	if stmt.RemoveManagedFields {
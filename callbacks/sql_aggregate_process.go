@@ -0,0 +1,240 @@
+package callbacks
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// having、order by 中引用的聚合函数，如 count(*)、sum(spec.replicas)
+var aggregateRefPattern = regexp.MustCompile(`(?i)\b(count|sum|avg|min|max)\(([^)]*)\)`)
+
+// aggregateGroup 分组
+type aggregateGroup struct {
+	key   string
+	items []*unstructured.Unstructured
+}
+
+// executeAggregate 执行分组聚合
+// 按 group by 字段分组，计算每组的聚合值，每组生成一条结果行，再按 having 条件过滤。
+// 结果行中以列名为key，同时保留 group by 字段路径及聚合函数默认列名，供 having、order by 引用
+func executeAggregate(items []*unstructured.Unstructured, filter *kom.Filter) []*unstructured.Unstructured {
+	groups := groupItems(items, filter.GroupBy)
+	aggregates := collectAggregates(filter)
+
+	var records []*unstructured.Unstructured
+	for _, g := range groups {
+		record := map[string]interface{}{}
+		var first map[string]interface{}
+		if len(g.items) > 0 {
+			first = g.items[0].Object
+		}
+		for _, field := range filter.GroupBy {
+			record[field] = projectValue(first, field)
+		}
+		for _, c := range aggregates {
+			record[kom.AggregateName(c.Func, c.Field)] = aggregate(c.Func, c.Field, g.items)
+		}
+		for _, c := range filter.Projection {
+			if c.Func != "" {
+				record[c.Name()] = record[kom.AggregateName(c.Func, c.Field)]
+				continue
+			}
+			// 非分组、非聚合的列，取组内第一条的值
+			record[c.Name()] = projectValue(first, c.Field)
+		}
+		records = append(records, &unstructured.Unstructured{Object: record})
+	}
+
+	if filter.Having != nil {
+		records = executeFilter(records, &kom.Filter{Expr: filter.Having})
+	}
+	return records
+}
+
+// groupItems 按 group by 字段分组，分组按分组值排序，保证结果稳定
+// 没有 group by 字段时，所有数据为一组（即使没有数据也返回一组，如 count(*) 为 0）
+func groupItems(items []*unstructured.Unstructured, fields []string) []*aggregateGroup {
+	if len(fields) == 0 {
+		return []*aggregateGroup{{items: items}}
+	}
+	groupMap := map[string]*aggregateGroup{}
+	for _, item := range items {
+		var parts []string
+		for _, field := range fields {
+			parts = append(parts, fmt.Sprintf("%v", projectValue(item.Object, field)))
+		}
+		key := strings.Join(parts, "\x00")
+		g, ok := groupMap[key]
+		if !ok {
+			g = &aggregateGroup{key: key}
+			groupMap[key] = g
+		}
+		g.items = append(g.items, item)
+	}
+	groups := make([]*aggregateGroup, 0, len(groupMap))
+	for _, g := range groupMap {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].key < groups[j].key
+	})
+	return groups
+}
+
+// collectAggregates 收集需要计算的聚合函数
+// 包括 select 中的聚合列，以及 having、order by 中引用的聚合函数
+func collectAggregates(filter *kom.Filter) []*kom.Column {
+	var columns []*kom.Column
+	seen := map[string]bool{}
+	add := func(fn, field string) {
+		name := kom.AggregateName(fn, field)
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		columns = append(columns, &kom.Column{Func: fn, Field: field})
+	}
+	for _, c := range filter.Projection {
+		if c.Func != "" {
+			add(c.Func, c.Field)
+		}
+	}
+	refs := []string{filter.Order}
+	walkConditions(filter.Having, func(c *kom.Condition) {
+		refs = append(refs, c.Field)
+	})
+	for _, ref := range refs {
+		for _, m := range aggregateRefPattern.FindAllStringSubmatch(ref, -1) {
+			add(strings.ToLower(m[1]), strings.TrimSpace(m[2]))
+		}
+	}
+	return columns
+}
+
+// walkConditions 遍历表达式树中的所有条件
+func walkConditions(node *kom.WhereNode, fn func(c *kom.Condition)) {
+	if node == nil {
+		return
+	}
+	if node.Condition != nil {
+		fn(node.Condition)
+	}
+	for _, child := range node.Children {
+		walkConditions(child, fn)
+	}
+}
+
+// aggregate 计算一组数据的聚合值
+func aggregate(fn string, field string, items []*unstructured.Unstructured) interface{} {
+	if fn == "count" && field == "*" {
+		return int64(len(items))
+	}
+
+	var values []string
+	var count int64
+	for _, item := range items {
+		fieldValues, found, err := getNestedFieldAsString(item.Object, field)
+		if err != nil || !found {
+			continue
+		}
+		count++
+		values = append(values, fieldValues...)
+	}
+
+	switch fn {
+	case "count":
+		return count
+	case "sum":
+		sum, _ := sumValues(values)
+		return sum
+	case "avg":
+		return avgValues(values)
+	case "min", "max":
+		if len(values) == 0 {
+			return nil
+		}
+		result := values[0]
+		for _, v := range values[1:] {
+			c := compareFieldValues(v, result)
+			if (fn == "min" && c < 0) || (fn == "max" && c > 0) {
+				result = v
+			}
+		}
+		return typedValue(result)
+	}
+	return nil
+}
+
+// sumValues 求和，支持 k8s Quantity
+// 全部为普通数字时返回 float64，包含单位（如 500m、1Gi）时按 Quantity 求和，返回 Quantity 字符串。
+// 同时返回参与计算的值的个数
+func sumValues(values []string) (interface{}, int) {
+	var total resource.Quantity
+	var floatSum float64
+	plain := true
+	count := 0
+	for _, v := range values {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			klog.V(6).Infof("sum skip value %s: %v", v, err)
+			continue
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			floatSum += f
+		} else {
+			plain = false
+		}
+		total.Add(q)
+		count++
+	}
+	if count == 0 {
+		return nil, 0
+	}
+	if plain {
+		return floatSum, count
+	}
+	return total.String(), count
+}
+
+// avgValues 求平均值，支持 k8s Quantity
+func avgValues(values []string) interface{} {
+	sum, count := sumValues(values)
+	switch v := sum.(type) {
+	case float64:
+		return v / float64(count)
+	case string:
+		q := resource.MustParse(v)
+		avg := q.AsApproximateFloat64() / float64(count)
+		return resource.NewMilliQuantity(int64(math.Round(avg*1000)), q.Format).String()
+	}
+	return nil
+}
+
+// typedValue 数字类型的值转换为 float64，其他保持字符串
+func typedValue(v string) interface{} {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+	return v
+}
+
+// aggregateRow 从分组结果中取出查询列，生成结果行
+func aggregateRow(record *unstructured.Unstructured, columns []*kom.Column) kom.Row {
+	if len(columns) == 0 {
+		return record.Object
+	}
+	row := kom.Row{}
+	for _, c := range columns {
+		row[c.Name()] = record.Object[c.Name()]
+	}
+	return row
+}
@@ -0,0 +1,112 @@
+package callbacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// listClusters 跨集群查询
+// 并发查询各目标集群，每个集群分别执行子查询及 join，按各自的下推计划获取列表并执行 where 条件，
+// 结果对象增加 cluster 字段后合并。部分集群失败时记录到 ClusterErrors，只有全部失败才返回错误。
+// 返回合并后的结果及获取到的对象总数
+func listClusters(k *kom.Kubectl) ([]*unstructured.Unstructured, int, error) {
+	stmt := k.Statement
+	plan := k.PushdownPlan()
+	klog.V(6).Infof("cluster pushdown plan:\n%s", plan.Explain())
+
+	type clusterResult struct {
+		items   []*unstructured.Unstructured
+		fetched int
+		err     error
+	}
+	results := make([]clusterResult, len(plan.Clusters))
+	var wg sync.WaitGroup
+	for i, id := range plan.Clusters {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			results[i].items, results[i].fetched, results[i].err = listCluster(k, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	var merged []*unstructured.Unstructured
+	var fetched int
+	clusterErrors := map[string]error{}
+	for i, id := range plan.Clusters {
+		r := results[i]
+		if r.err != nil {
+			klog.V(2).Infof("list cluster %s error: %v", id, r.err)
+			clusterErrors[id] = r.err
+			continue
+		}
+		merged = append(merged, r.items...)
+		fetched += r.fetched
+	}
+	if stmt.ClusterErrors != nil {
+		*stmt.ClusterErrors = clusterErrors
+	}
+	if len(plan.Clusters) > 0 && len(clusterErrors) == len(plan.Clusters) {
+		var msgs []string
+		for id, err := range clusterErrors {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", id, err))
+		}
+		sort.Strings(msgs)
+		return nil, 0, fmt.Errorf("all clusters failed: %s", strings.Join(msgs, "; "))
+	}
+	return merged, fetched, nil
+}
+
+// listCluster 在单个集群中执行查询，结果对象增加 cluster 字段
+// 表名按该集群重新解析，命名空间、列表参数及过滤条件沿用原语句，子查询及 join 的表均在该集群中查询
+func listCluster(k *kom.Kubectl, id string) ([]*unstructured.Unstructured, int, error) {
+	stmt := k.Statement
+	cluster := kom.Cluster(id)
+	if cluster == nil {
+		return nil, 0, fmt.Errorf("cluster %s not found", id)
+	}
+	tx := cluster.WithContext(stmt.Context).WithCache(stmt.CacheTTL).From(stmt.Filter.From)
+	if tx.Error != nil {
+		return nil, 0, tx.Error
+	}
+	tx.Statement.AllNamespace = stmt.AllNamespace
+	tx.Statement.Namespace = stmt.Namespace
+	tx.Statement.NamespaceList = stmt.NamespaceList
+	tx.Statement.ListOptions = stmt.ListOptions
+	tx.Statement.Filter = stmt.Filter
+	filter, err := tx.ResolveSubqueries()
+	if err != nil {
+		return nil, 0, err
+	}
+	tx.Statement.Filter = filter
+
+	plan := tx.PushdownPlan()
+	items, err := listItems(tx, plan)
+	if err != nil {
+		return nil, 0, err
+	}
+	fetched := len(items)
+	if len(tx.Statement.Filter.Joins) > 0 {
+		if items, err = executeJoin(tx, items); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var result []*unstructured.Unstructured
+	for _, item := range items {
+		// 列表可能来自缓存，复制顶层字段后再增加 cluster 字段，不修改缓存中的对象
+		obj := make(map[string]interface{}, len(item.Object)+1)
+		for key, value := range item.Object {
+			obj[key] = value
+		}
+		obj[kom.ClusterColumn] = id
+		result = append(result, &unstructured.Unstructured{Object: obj})
+	}
+	return executeFilter(result, &kom.Filter{Expr: plan.Residual}), fetched, nil
+}
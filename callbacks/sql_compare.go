@@ -0,0 +1,70 @@
+package callbacks
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// compareFieldValues 按值的类型比较两个字段值，返回 -1、0、1
+// 按 b 探测到的类型（数字、时间、k8s Quantity、Duration）比较，无法按类型比较时按字符串比较
+func compareFieldValues(a, b string) int {
+	if t, typed := utils.DetectType(b); t != utils.TypeString {
+		if c, ok := compareTypedValue(a, typed); ok {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// compareTypedValue 将字段值按条件值的类型进行比较，返回 -1、0、1，无法比较时 ok 为 false
+// 条件值为数字时，字段值可以是 Quantity，如 cpu 500m < 1
+// 条件值为 Quantity 时，字段值无法解析为 Quantity 的，再尝试按 Duration 比较，如 5m
+func compareTypedValue(fieldValue string, value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return compareNumber(fieldValue, v)
+	case int:
+		return compareNumber(fieldValue, float64(v))
+	case int64:
+		return compareNumber(fieldValue, float64(v))
+	case resource.Quantity:
+		if q, err := resource.ParseQuantity(fieldValue); err == nil {
+			return q.Cmp(v), true
+		}
+		if d, err := time.ParseDuration(v.String()); err == nil {
+			return compareTypedValue(fieldValue, d)
+		}
+	case time.Duration:
+		if d, err := time.ParseDuration(fieldValue); err == nil {
+			return cmp.Compare(d, v), true
+		}
+	case time.Time:
+		if t, err := utils.ParseTime(fieldValue); err == nil {
+			return t.Compare(v), true
+		}
+	case string:
+		// 未经过类型探测的条件值，按数字比较
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return compareNumber(fieldValue, f)
+		}
+	}
+	return 0, false
+}
+
+// compareNumber 字段值与数字比较，字段值可以是数字或 Quantity
+func compareNumber(fieldValue string, v float64) (int, bool) {
+	if f, err := strconv.ParseFloat(fieldValue, 64); err == nil {
+		return cmp.Compare(f, v), true
+	}
+	if q, err := resource.ParseQuantity(fieldValue); err == nil {
+		if vq, err := resource.ParseQuantity(strconv.FormatFloat(v, 'f', -1, 64)); err == nil {
+			return q.Cmp(vq), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,104 @@
+package callbacks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// executeJoin 执行 join 查询
+// 主表的数据以表别名为key组装为结果记录，再按顺序与每张 join 的表进行 hash join。
+// join 的表同样通过 List 获取，沿用 List 的回调链及缓存设置
+func executeJoin(k *kom.Kubectl, items []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	stmt := k.Statement
+	records := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		records = append(records, &unstructured.Unstructured{Object: map[string]interface{}{
+			stmt.Filter.TableAlias: item.Object,
+		}})
+	}
+
+	for _, join := range stmt.Filter.Joins {
+		var right []*unstructured.Unstructured
+		err := kom.Cluster(k.ID).
+			WithContext(stmt.Context).
+			WithCache(stmt.CacheTTL).
+			GVK(join.GVK.Group, join.GVK.Version, join.GVK.Kind).
+			AllNamespace().
+			List(&right).Error
+		if err != nil {
+			return nil, fmt.Errorf("join %s error: %v", join.Table, err)
+		}
+		records = hashJoin(records, right, join)
+	}
+	return records, nil
+}
+
+// hashJoin 以被连接的表构建 hash 索引，逐条探测已有的结果记录
+// 字段为数组时（如 metadata.ownerReferences.name），任意一个值相等即视为匹配
+// left join 未匹配到的记录保留，被连接表对应的字段为空
+func hashJoin(records []*unstructured.Unstructured, right []*unstructured.Unstructured, join *kom.Join) []*unstructured.Unstructured {
+	rightFields := make([]string, 0, len(join.On))
+	leftFields := make([]string, 0, len(join.On))
+	for _, on := range join.On {
+		rightFields = append(rightFields, on.Right)
+		leftFields = append(leftFields, on.Left)
+	}
+
+	index := map[string][]*unstructured.Unstructured{}
+	for _, item := range right {
+		for _, key := range joinKeys(item.Object, rightFields) {
+			index[key] = append(index[key], item)
+		}
+	}
+
+	var result []*unstructured.Unstructured
+	name := join.Name()
+	for _, record := range records {
+		matched := map[*unstructured.Unstructured]bool{}
+		for _, key := range joinKeys(record.Object, leftFields) {
+			for _, item := range index[key] {
+				if matched[item] {
+					continue
+				}
+				matched[item] = true
+				obj := make(map[string]interface{}, len(record.Object)+1)
+				for k, v := range record.Object {
+					obj[k] = v
+				}
+				obj[name] = item.Object
+				result = append(result, &unstructured.Unstructured{Object: obj})
+			}
+		}
+		if len(matched) == 0 && join.Type == kom.JoinLeft {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// joinKeys 计算连接字段的 hash key
+// 字段为数组时取值的笛卡尔积，任一字段不存在时返回空
+func joinKeys(obj map[string]interface{}, fields []string) []string {
+	keys := []string{""}
+	for i, field := range fields {
+		values, found, err := getNestedFieldAsString(obj, field)
+		if err != nil || !found {
+			return nil
+		}
+		var next []string
+		for _, key := range keys {
+			for _, v := range values {
+				if i > 0 {
+					next = append(next, strings.Join([]string{key, v}, "\x00"))
+				} else {
+					next = append(next, v)
+				}
+			}
+		}
+		keys = next
+	}
+	return keys
+}
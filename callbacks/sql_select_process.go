@@ -0,0 +1,49 @@
+package callbacks
+
+import (
+	"reflect"
+
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// isRowType 判断 List 的目标元素是否为结果行类型
+// 支持 kom.Row 及 map[string]interface{}
+func isRowType(elemType reflect.Type) bool {
+	return elemType.Kind() == reflect.Map &&
+		elemType.Key().Kind() == reflect.String &&
+		elemType.Elem().Kind() == reflect.Interface
+}
+
+// projectRow 按照查询列生成结果行
+// 未指定查询列（select *）时，返回完整对象
+// 字段路径经过数组时（如 spec.containers.image），返回该路径下所有值组成的数组；字段不存在时为 nil
+func projectRow(item *unstructured.Unstructured, columns []*kom.Column, removeManagedFields bool) kom.Row {
+	if len(columns) == 0 {
+		obj := item.DeepCopy()
+		if removeManagedFields {
+			utils.RemoveManagedFields(obj)
+		}
+		return obj.Object
+	}
+
+	row := kom.Row{}
+	for _, c := range columns {
+		row[c.Name()] = projectValue(item.Object, c.Field)
+	}
+	return row
+}
+
+// projectValue 获取查询列的值，字段不存在时为 nil
+// 路径经过数组时即使只有一个值也返回 []interface{}，不经过数组时返回字段值
+func projectValue(obj map[string]interface{}, field string) interface{} {
+	values, found, err := getNestedFieldValues(obj, field)
+	if err != nil || !found {
+		return nil
+	}
+	if len(values) == 1 && !traversesArray(obj, field) {
+		return values[0]
+	}
+	return values
+}
@@ -11,96 +11,51 @@ import (
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/klog/v2"
 )
 
-// executeFilter 使用 lancet 执行过滤
-func executeFilter(result []*unstructured.Unstructured, conditions []*kom.Condition) []*unstructured.Unstructured {
-
-	// 最终的结果，按照条件列表，逐一执行过滤
-
-	// 2. 按 and or 分组
-	groupedConditions := groupByOperator(conditions)
-
-	// 3. 目前暂时改为支持简单的 and or 逻辑。不管括号。
-	// TODO 需要处理复杂的括号等逻辑情况
-	// keys and or
-	keys := make([]string, 0, len(groupedConditions))
-	for k := range groupedConditions {
-		keys = append(keys, k)
-	}
-
-	// 3. 按and or 两组 逐个访问 map 并处理
-	for _, key := range keys {
-		// 这个key == and or
-		group := slice.Filter(conditions, func(index int, item *kom.Condition) bool {
-			return item.AndOr == key
-		})
-		// 按组进行过滤，一般一组为相同的and or 条件
-		result = evaluateCondition(result, group)
-
-	}
-
-	return result
-}
-
-func groupByDepth(conditions []kom.Condition) map[int][]kom.Condition {
-	groups := make(map[int][]kom.Condition)
-	for _, p := range conditions {
-		depth := p.Depth
-		groups[depth] = append(groups[depth], p)
-	}
-	return groups
-}
-func groupByOperator(conditions []*kom.Condition) map[string][]*kom.Condition {
-	groups := make(map[string][]*kom.Condition)
-	for _, p := range conditions {
-		operator := p.AndOr
-		groups[operator] = append(groups[operator], p)
-	}
-	return groups
-}
-func evaluateCondition(result []*unstructured.Unstructured, group []*kom.Condition) []*unstructured.Unstructured {
-
-	// 一般一组 and or 都相同
-	if group[0].AndOr == "OR" {
-		return matchAny(result, group)
-	} else {
-		return matchAll(result, group)
+// executeFilter 执行where条件过滤
+// 按照表达式树递归求值，支持括号、NOT 以及 AND/OR 的优先级
+func executeFilter(result []*unstructured.Unstructured, filter *kom.Filter) []*unstructured.Unstructured {
+	tree := filter.WhereTree()
+	if tree == nil {
+		return result
 	}
+	return slice.Filter(result, func(index int, item *unstructured.Unstructured) bool {
+		return evaluateWhere(item, tree)
+	})
 }
 
-// matchAll 判断所有条件都满足 (AND 逻辑)
-func matchAll(result []*unstructured.Unstructured, conditions []*kom.Condition) []*unstructured.Unstructured {
-	return slice.Filter(result, func(index int, item *unstructured.Unstructured) bool {
-		// 遍历所有条件，只有全部条件成立才返回 true
-		for _, c := range conditions {
-			condition := matchCondition(item, c)
-			klog.V(8).Infof("matchAny %s/%s  %s  %s  %s = %v", item.GetNamespace(), item.GetName(), c.Field, c.Operator, c.Value, condition)
-			if !condition {
+// evaluateWhere 递归计算表达式树
+// AND 节点全部子节点成立才成立，OR 节点任一子节点成立即成立，NOT 节点对子节点取反
+func evaluateWhere(item *unstructured.Unstructured, node *kom.WhereNode) bool {
+	switch node.Op {
+	case kom.WhereOpAnd:
+		for _, child := range node.Children {
+			if !evaluateWhere(item, child) {
 				return false // 只要有一个条件不成立，直接返回 false
 			}
 		}
-		return true // 所有条件都成立，返回 true
-	})
-}
-
-// matchAny 判断任一条件满足 (OR 逻辑)
-func matchAny(result []*unstructured.Unstructured, conditions []*kom.Condition) []*unstructured.Unstructured {
-	return slice.Filter(result, func(index int, item *unstructured.Unstructured) bool {
-		// 遍历所有条件，任意一个条件成立就返回 true
-		for _, c := range conditions {
-			condition := matchCondition(item, c)
-			klog.V(8).Infof("matchAny %s/%s  %s  %s  %s = %v", item.GetNamespace(), item.GetName(), c.Field, c.Operator, c.Value, condition)
-			if condition {
-				// 任意一个条件达成，就返回。
-				// 没有达成不要返回，让他执行下一个条件
-				return true
+		return true
+	case kom.WhereOpOr:
+		for _, child := range node.Children {
+			if evaluateWhere(item, child) {
+				return true // 任意一个条件达成，就返回 true
 			}
 		}
 		return false
-	})
+	case kom.WhereOpNot:
+		return !evaluateWhere(item, node.Children[0])
+	case kom.WhereOpCondition:
+		c := node.Condition
+		matched := matchCondition(item, c)
+		klog.V(8).Infof("evaluateWhere %s/%s  %s  %s  %v = %v", item.GetNamespace(), item.GetName(), c.Field, c.Operator, c.Value, matched)
+		return matched
+	default:
+		return false
+	}
 }
 
 // matchCondition 判断单个条件是否匹配
@@ -118,158 +73,134 @@ func matchAny(result []*unstructured.Unstructured, conditions []*kom.Condition)
 //
 // 对于 正向操作符（如 =, like, in, between），只要找到一个匹配的值就返回 true。
 // 对于 负向操作符（如 !=, not in, not between），则要确保所有值都不匹配才返回 true。
+// 可以使用 any()、all() 显式指定，如 all(spec.containers.image) like 'nginx%' 要求所有容器镜像都匹配
 func matchCondition(resource *unstructured.Unstructured, condition *kom.Condition) bool {
+	// 含 now() 的条件按当前时间重新计算条件值
+	condition = condition.Refreshed()
 	klog.V(6).Infof("matchCondition  %s %s %s", condition.Field, condition.Operator, condition.Value)
 
-	// 获取字段值
-	fieldValues, found, err := getNestedFieldAsString(resource.Object, condition.Field)
-	if err != nil || !found {
-		klog.V(6).Infof("not found %s,%v", condition.Field, err)
+	// 获取字段值，函数条件获取函数的计算结果，如 lower(metadata.name)
+	var fieldValues []string
+	var found bool
+	var err error
+	if condition.Func != nil {
+		fieldValues, found, err = getFuncValuesAsString(resource.Object, condition.Func)
+	} else {
+		fieldValues, found, err = getNestedFieldAsString(resource.Object, condition.Field)
+	}
+	if err != nil {
+		klog.V(6).Infof("get %s error %v", condition.Field, err)
 		return false
 	}
+	return matchValues(fieldValues, found, condition)
+}
 
-	// 获取到的值是一个值，不是列表，直接处理
-	if len(fieldValues) == 1 {
-		fieldValue := fieldValues[0]
-		switch condition.Operator {
-		case "=":
-			if compareValue(fieldValue, condition.Value) {
-				return true
-			}
-		case "!=":
-			if compareValue(fieldValue, condition.Value) {
-				return false // 负向条件，找到一个匹配值即返回 false
-			}
-		case "like":
-			if compareLike(fieldValue, condition.Value) {
-				return true
-			}
-		case "in":
-			if compareIn(fieldValue, condition.Value) {
-				return true
-			}
-		case "not in":
-			if compareIn(fieldValue, condition.Value) {
-				return false
-			}
-		case ">":
-			if compareGreater(fieldValue, condition.Value) {
-				return true
-			}
-		case "<":
-			if compareLess(fieldValue, condition.Value) {
-				return true
-			}
-		case ">=":
-			if compareGreaterOrEqual(fieldValue, condition.Value) {
-				return true
-			}
-		case "<=":
-			if compareLessOrEqual(fieldValue, condition.Value) {
-				return true
-			}
-		case "between":
-			if compareBetween(fieldValue, condition.Value) {
-				return true
-			}
-		case "not between":
-			if compareBetween(fieldValue, condition.Value) {
-				return false
-			}
-		default:
-			return false
-		}
+// matchValues 判断获取到的字段值是否满足条件
+func matchValues(fieldValues []string, found bool, condition *kom.Condition) bool {
+	switch condition.Operator {
+	case "is null":
+		return !found
+	case "is not null":
+		return found
+	}
+	if !found {
+		klog.V(6).Infof("not found %s", condition.Field)
+		return false
 	}
 
-	// 获取到的值，是一个列表，属于yaml中的列表属性，那么需要综合思考了。
-
-	// 判断是正向条件还是负向条件
-	isNegativeCondition := condition.Operator == "!=" || condition.Operator == "not in" || condition.Operator == "not between"
+	quantifier := condition.Quantifier
+	if quantifier == "" {
+		// 正向条件找到一个匹配值即可，负向条件需要所有值都不匹配
+		quantifier = kom.QuantifierAny
+		if isNegativeOperator(condition.Operator) {
+			quantifier = kom.QuantifierAll
+		}
+	}
 
-	// 处理每个字段值
 	for _, fieldValue := range fieldValues {
-		// 对于负向条件（!=, not in, not between），需要确保所有值都不满足条件
-		switch condition.Operator {
-		case "=":
-			if !isNegativeCondition && compareValue(fieldValue, condition.Value) {
-				return true // 正向条件，找到一个值匹配即返回 true
-			}
-		case "!=":
-			if isNegativeCondition && compareValue(fieldValue, condition.Value) {
-				return false // 负向条件，找到一个匹配值即返回 false
-			}
-		case "like":
-			if !isNegativeCondition && compareLike(fieldValue, condition.Value) {
-				return true
-			}
-		case "in":
-			if !isNegativeCondition && compareIn(fieldValue, condition.Value) {
-				return true
-			}
-		case "not in":
-			if isNegativeCondition && compareIn(fieldValue, condition.Value) {
-				return false
-			}
-		case ">":
-			if !isNegativeCondition && compareGreater(fieldValue, condition.Value) {
-				return true
-			}
-		case "<":
-			if !isNegativeCondition && compareLess(fieldValue, condition.Value) {
-				return true
-			}
-		case ">=":
-			if !isNegativeCondition && compareGreaterOrEqual(fieldValue, condition.Value) {
-				return true
-			}
-		case "<=":
-			if !isNegativeCondition && compareLessOrEqual(fieldValue, condition.Value) {
-				return true
-			}
-		case "between":
-			if !isNegativeCondition && compareBetween(fieldValue, condition.Value) {
-				return true
-			}
-		case "not between":
-			if isNegativeCondition && compareBetween(fieldValue, condition.Value) {
-				return false
-			}
-		default:
-			return false
+		matched := matchValue(fieldValue, condition)
+		if quantifier == kom.QuantifierAny && matched {
+			return true // 任一值满足即返回 true
+		}
+		if quantifier == kom.QuantifierAll && !matched {
+			return false // 任一值不满足即返回 false
 		}
 	}
+	return quantifier == kom.QuantifierAll
+}
 
-	// 负向条件的情况：只有所有值都不满足条件，才认为不满足条件
-	if isNegativeCondition {
-		return true // 所有值都不满足条件，返回 true
+// isNegativeOperator 是否为负向操作符
+func isNegativeOperator(operator string) bool {
+	switch operator {
+	case "!=", "not in", "not between", "not like", "not regexp":
+		return true
 	}
-
-	// 默认返回 false，表示没有任何一个值满足条件
 	return false
 }
 
+// matchValue 判断单个值是否满足条件
+func matchValue(fieldValue string, condition *kom.Condition) bool {
+	switch condition.Operator {
+	case "=":
+		return compareValue(fieldValue, condition.Value)
+	case "!=":
+		return !compareValue(fieldValue, condition.Value)
+	case "like":
+		return compareLike(fieldValue, condition.Value)
+	case "not like":
+		return !compareLike(fieldValue, condition.Value)
+	case "in":
+		return compareIn(fieldValue, condition.Value)
+	case "not in":
+		return !compareIn(fieldValue, condition.Value)
+	case ">":
+		return compareGreater(fieldValue, condition.Value)
+	case "<":
+		return compareLess(fieldValue, condition.Value)
+	case ">=":
+		return compareGreaterOrEqual(fieldValue, condition.Value)
+	case "<=":
+		return compareLessOrEqual(fieldValue, condition.Value)
+	case "between":
+		return compareBetween(fieldValue, condition)
+	case "not between":
+		return !compareBetween(fieldValue, condition)
+	case "regexp":
+		return compareRegexp(fieldValue, condition)
+	case "not regexp":
+		return !compareRegexp(fieldValue, condition)
+	default:
+		return false
+	}
+}
+
+// compareRegexp 判断字符串是否匹配正则表达式，区分大小写，可使用 (?i) 忽略大小写
+func compareRegexp(fieldValue string, condition *kom.Condition) bool {
+	klog.V(6).Infof("compareRegexp (regexp) %s,%v", fieldValue, condition.Value)
+	pattern := condition.Pattern
+	if pattern == nil {
+		// 未经过解析的条件，按需编译
+		var err error
+		if pattern, err = regexp.Compile(fmt.Sprintf("%v", condition.Value)); err != nil {
+			return false
+		}
+	}
+	return pattern.MatchString(fieldValue)
+}
+
 // compareValue 比较值是否相等，不区分大小写
+// 数字、Quantity、Duration、时间按类型比较，如 1Gi = 1024Mi
 func compareValue(fieldValue string, value interface{}) bool {
 	klog.V(8).Infof("compareValue (=) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
 
 	switch v := value.(type) {
 	case string:
 		return strings.ToLower(fieldValue) == strings.ToLower(v)
-	case float64, int, int64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat == v
-	case time.Time:
-		// 需要将 fieldValue 转换为 time 类型进行比较
-		fieldTime, err := time.Parse(time.RFC3339, fieldValue)
-		if err != nil {
-			return false
-		}
-		return fieldTime.Equal(v)
+	case bool:
+		return strings.ToLower(fieldValue) == strconv.FormatBool(v)
 	default:
-		return false
+		c, ok := compareTypedValue(fieldValue, value)
+		return ok && c == 0
 	}
 }
 
@@ -307,157 +238,29 @@ func compareLike(fieldValue string, value interface{}) bool {
 // compareGreater 比较数值是否大于
 func compareGreater(fieldValue string, value interface{}) bool {
 	klog.V(6).Infof("compareGreater(>) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-	switch v := value.(type) {
-	case float64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat > v
-	case int, int64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat > float64(v.(int))
-	case string:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		valueFloat, err := strconv.ParseFloat(v, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat > valueFloat
-	case time.Time:
-		fieldValTime, err := utils.ParseTime(fieldValue)
-		if err != nil {
-			return false
-		}
-
-		return fieldValTime.After(v)
-	default:
-		klog.V(6).Infof("%s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-		return false
-	}
+	c, ok := compareTypedValue(fieldValue, value)
+	return ok && c > 0
 }
 
 // compareLess 比较数值是否小于
 func compareLess(fieldValue string, value interface{}) bool {
 	klog.V(6).Infof("compareLess(<) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-
-	switch v := value.(type) {
-	case float64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat < v
-	case int, int64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat < float64(v.(int))
-	case string:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		valueFloat, err := strconv.ParseFloat(v, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat < valueFloat
-	case time.Time:
-		fieldValTime, err := utils.ParseTime(fieldValue)
-		if err != nil {
-			return false
-		}
-		return fieldValTime.Before(v)
-	default:
-		klog.V(6).Infof("%s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-		return false
-	}
+	c, ok := compareTypedValue(fieldValue, value)
+	return ok && c < 0
 }
 
 // compareGreaterOrEqual 比较数值是否大于或等于
 func compareGreaterOrEqual(fieldValue string, value interface{}) bool {
 	klog.V(6).Infof("compareGreaterOrEqual(>=) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-	switch v := value.(type) {
-	case float64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat >= v
-	case int, int64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat >= float64(v.(int))
-	case string:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		valueFloat, err := strconv.ParseFloat(v, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat >= valueFloat
-	case time.Time:
-		fieldValTime, err := utils.ParseTime(fieldValue)
-		if err != nil {
-			return false
-		}
-		return fieldValTime.After(v) || fieldValTime.Equal(v)
-	default:
-		klog.V(6).Infof("%s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-		return false
-	}
-
+	c, ok := compareTypedValue(fieldValue, value)
+	return ok && c >= 0
 }
 
 // compareLessOrEqual 比较数值是否小于或等于
 func compareLessOrEqual(fieldValue string, value interface{}) bool {
 	klog.V(6).Infof("compareLessOrEqual(<=) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-
-	switch v := value.(type) {
-	case float64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat <= v
-	case int, int64:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat <= float64(v.(int))
-	case string:
-		fieldValFloat, err := strconv.ParseFloat(fieldValue, 64)
-		if err != nil {
-			return false
-		}
-		valueFloat, err := strconv.ParseFloat(v, 64)
-		if err != nil {
-			return false
-		}
-		return fieldValFloat <= valueFloat
-	case time.Time:
-		fieldValTime, err := utils.ParseTime(fieldValue)
-		if err != nil {
-			return false
-		}
-		return fieldValTime.Before(v) || fieldValTime.Equal(v)
-	default:
-		klog.V(6).Infof("%s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
-		return false
-	}
+	c, ok := compareTypedValue(fieldValue, value)
+	return ok && c <= 0
 }
 
 // compareIn 判断值是否在列表中
@@ -465,55 +268,66 @@ func compareIn(fieldValue string, value interface{}) bool {
 
 	klog.V(6).Infof("compareIn(in []) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
 
-	// value 类型字符串 = (1,2,3,4) ，这个格式决定了只能是string类型
+	// value 类型字符串 = (1,2,3,4)，或者子查询返回的值列表 []string
 	// 如何判断fieldValue 是否在1,2,3,4范围内?
-	if str, ok := value.(string); ok {
+	var values []string
+	switch v := value.(type) {
+	case string:
 		// 去掉首尾的括号
-		str = strings.TrimPrefix(str, "(")
+		str := strings.TrimPrefix(v, "(")
 		str = strings.TrimSuffix(str, ")")
 		// 以逗号分割
-		values := strings.Split(str, ",")
-		for _, v := range values {
-			v = strings.Trim(v, " ")
-			v = utils.TrimQuotes(v)
-			// 时间、字符串、数字
-			// 只有相等，才能返回，因为in操作符，是or的关系。一个不行，需要判断下一个。
-
-			// 先按数字比较
-			fieldValueNum, err1 := strconv.ParseFloat(fieldValue, 64)
-			toNum, err2 := strconv.ParseFloat(v, 64)
-			if err1 == nil && err2 == nil {
-				if fieldValueNum == toNum {
-					return true
-				}
+		for _, item := range strings.Split(str, ",") {
+			values = append(values, utils.TrimQuotes(strings.Trim(item, " ")))
+		}
+	case []string:
+		values = v
+	}
+	for _, v := range values {
+		// 时间、字符串、数字
+		// 只有相等，才能返回，因为in操作符，是or的关系。一个不行，需要判断下一个。
+
+		// 先按数字比较
+		fieldValueNum, err1 := strconv.ParseFloat(fieldValue, 64)
+		toNum, err2 := strconv.ParseFloat(v, 64)
+		if err1 == nil && err2 == nil {
+			if fieldValueNum == toNum {
+				return true
 			}
+		}
 
-			// 时间不能简单判断，而要判断是否日期、小时、分钟，是否in。
-			// 是否包含时间部分，如果包含，就是精确匹配。如果不不含，就是判断日期
-			fieldValueTime, err1 := utils.ParseTime(fieldValue)
-			toTime, err2 := utils.ParseTime(v)
-			if err1 == nil && err2 == nil {
-
-				// 判断目标时间字符串是否包含时间部分（即时分秒）
-				if hasTimeComponent(v) {
-					// 逐级比较时间分量（小时、分钟、秒）
-					if fieldValueTime.Hour() == toTime.Hour() &&
-						fieldValueTime.Minute() == toTime.Minute() &&
-						fieldValueTime.Second() == toTime.Second() {
-						return true
-					}
-				}
-				// 比较日期部分（年、月、日）
-				if isSameDate(fieldValueTime, toTime) {
+		// 按 Quantity 比较，如 1Gi in ('1024Mi')
+		fieldValueQuantity, err1 := resource.ParseQuantity(fieldValue)
+		toQuantity, err2 := resource.ParseQuantity(v)
+		if err1 == nil && err2 == nil && fieldValueQuantity.Cmp(toQuantity) == 0 {
+			return true
+		}
+
+		// 时间不能简单判断，而要判断是否日期、小时、分钟，是否in。
+		// 是否包含时间部分，如果包含，就是精确匹配。如果不不含，就是判断日期
+		fieldValueTime, err1 := utils.ParseTime(fieldValue)
+		toTime, err2 := utils.ParseTime(v)
+		if err1 == nil && err2 == nil {
+
+			// 判断目标时间字符串是否包含时间部分（即时分秒）
+			if hasTimeComponent(v) {
+				// 逐级比较时间分量（小时、分钟、秒）
+				if fieldValueTime.Hour() == toTime.Hour() &&
+					fieldValueTime.Minute() == toTime.Minute() &&
+					fieldValueTime.Second() == toTime.Second() {
 					return true
 				}
 			}
-
-			if fieldValue == v {
+			// 比较日期部分（年、月、日）
+			if isSameDate(fieldValueTime, toTime) {
 				return true
 			}
+		}
 
+		if fieldValue == v {
+			return true
 		}
+
 	}
 	return false
 }
@@ -531,7 +345,12 @@ func isSameDate(t1, t2 time.Time) bool {
 }
 
 // compareBetween 判断值是否在范围内
-func compareBetween(fieldValue string, value interface{}) bool {
+// 经过解析的条件按 From、To 的类型比较，未经过解析的条件解析 Value 中的 from and to
+func compareBetween(fieldValue string, condition *kom.Condition) bool {
+	if condition.From != nil && condition.To != nil {
+		return compareRange(fieldValue, condition.From, condition.To)
+	}
+	value := condition.Value
 	klog.V(6).Infof("compareBetween (between x and y) %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
 
 	// value格式 举例: 1 and 5 这个格式决定了只能是string类型
@@ -574,12 +393,114 @@ func compareBetween(fieldValue string, value interface{}) bool {
 		}
 	}
 
-	// 3. 作为字符串比较
+	// 3. 尝试作为 Quantity、Duration 比较，如 cpu between 250m and 1
+	_, fromValue := utils.DetectType(from)
+	_, toValue := utils.DetectType(to)
+	c1, ok1 := compareTypedValue(fieldValue, fromValue)
+	c2, ok2 := compareTypedValue(fieldValue, toValue)
+	if ok1 && ok2 {
+		klog.V(6).Infof("compareBetween(between x and y) as typed value %s,%v(%v)", fieldValue, value, reflect.TypeOf(value))
+		return c1 >= 0 && c2 <= 0
+	}
+
+	// 4. 作为字符串比较
 	return fieldValue >= from && fieldValue <= to
 }
 
+// compareRange 按范围值的类型判断是否在范围内，字符串按字典序比较，其余按类型比较
+func compareRange(fieldValue string, from, to interface{}) bool {
+	klog.V(6).Infof("compareRange (between x and y) %s,%v(%v),%v(%v)", fieldValue, from, reflect.TypeOf(from), to, reflect.TypeOf(to))
+	fromStr, ok1 := from.(string)
+	toStr, ok2 := to.(string)
+	if ok1 && ok2 {
+		return fieldValue >= fromStr && fieldValue <= toStr
+	}
+	c1, ok1 := compareTypedValue(fieldValue, from)
+	c2, ok2 := compareTypedValue(fieldValue, to)
+	return ok1 && ok2 && c1 >= 0 && c2 <= 0
+}
+
 // getNestedFieldAsString 获取嵌套字段值，支持数组筛选并处理数组返回值
 func getNestedFieldAsString(obj interface{}, path string) ([]string, bool, error) {
+	values, found, err := getNestedFieldValues(obj, path)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	var results []string
+	for _, v := range values {
+		results = append(results, fmt.Sprintf("%v", v))
+	}
+	return results, true, nil
+}
+
+// getFuncValuesAsString 对资源对象计算函数，结果为切片时返回每一项，结果为 nil 时视为不存在
+func getFuncValuesAsString(obj map[string]interface{}, call *kom.FuncCall) ([]string, bool, error) {
+	value, err := call.Eval(obj, func(field string) interface{} {
+		return funcArgValue(obj, field)
+	})
+	if err != nil || value == nil {
+		return nil, false, err
+	}
+	var values []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+	case []string:
+		for _, item := range v {
+			values = append(values, item)
+		}
+	default:
+		values = []interface{}{v}
+	}
+	var results []string
+	for _, v := range values {
+		if t, ok := v.(time.Time); ok {
+			results = append(results, t.Format(time.RFC3339))
+			continue
+		}
+		results = append(results, fmt.Sprintf("%v", v))
+	}
+	return results, len(results) > 0, nil
+}
+
+// funcArgValue 获取函数的字段参数值，字段不存在时为 nil
+// 路径经过数组时（如 spec.containers.image）即使只有一个值也返回 []interface{}，len() 按元素个数计算
+func funcArgValue(obj map[string]interface{}, field string) interface{} {
+	return projectValue(obj, field)
+}
+
+// traversesArray 字段路径的中间是否经过数组
+func traversesArray(obj map[string]interface{}, path string) bool {
+	fields, _, err := parsePathWithCondition(path)
+	if err != nil {
+		return false
+	}
+	var current interface{} = obj
+	for _, field := range fields[:len(fields)-1] {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current = m[field]
+		if _, ok := current.([]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// getNestedFieldValues 获取嵌套字段的原始值，支持数组筛选
+// 路径经过数组时，会返回数组中每一项对应的值
+func getNestedFieldValues(obj interface{}, path string) ([]interface{}, bool, error) {
+	// 分组聚合后的结果行，列名即为完整路径，如 spec.nodeName、sum(spec.replicas)
+	if m, ok := obj.(map[string]interface{}); ok {
+		if val, exists := m[path]; exists {
+			if val == nil {
+				return nil, false, nil
+			}
+			return []interface{}{val}, true, nil
+		}
+	}
 	fields, arrayCondition, err := parsePathWithCondition(path)
 	if err != nil {
 		return nil, false, err
@@ -616,10 +537,10 @@ func parsePathWithCondition(path string) ([]string, map[string]string, error) {
 }
 
 // getFieldValues 递归获取字段值，支持数组筛选并返回多个值
-func getFieldValues(obj interface{}, fields []string, arrayCondition map[string]string) ([]string, bool, error) {
+func getFieldValues(obj interface{}, fields []string, arrayCondition map[string]string) ([]interface{}, bool, error) {
 	if len(fields) == 0 {
 		if obj != nil {
-			return []string{fmt.Sprintf("%v", obj)}, true, nil
+			return []interface{}{obj}, true, nil
 		}
 		return nil, false, nil
 	}
@@ -636,7 +557,7 @@ func getFieldValues(obj interface{}, fields []string, arrayCondition map[string]
 		return nil, false, nil
 	case []map[string]interface{}:
 		// 遍历数组，筛选符合条件的项
-		var results []string
+		var results []interface{}
 		for _, item := range v {
 			if matchCondition2(item, arrayCondition) {
 				// 条件匹配，递归获取剩余字段
@@ -648,23 +569,13 @@ func getFieldValues(obj interface{}, fields []string, arrayCondition map[string]
 		return results, len(results) > 0, nil
 	case []interface{}:
 		// 如果是 interface{} 数组，逐项判断
-		var results []string
+		var results []interface{}
 		for _, item := range v {
 			if val, found, err := getFieldValues(item, fields, arrayCondition); found || err != nil {
 				results = append(results, val...)
 			}
 		}
 		return results, len(results) > 0, nil
-	case string:
-		if len(fields) == 0 {
-			return []string{v}, true, nil
-		}
-		return nil, false, nil
-	case bool, int, int64, float64:
-		if len(fields) == 0 {
-			return []string{fmt.Sprintf("%v", v)}, true, nil
-		}
-		return nil, false, nil
 	default:
 		return nil, false, nil
 	}
@@ -3,9 +3,12 @@ package callbacks
 import (
 	"fmt"
 	"reflect"
+	"sync"
 
+	"github.com/duke-git/lancet/v2/slice"
 	"github.com/weibaohui/kom/kom"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
@@ -58,9 +61,76 @@ func Watch(k *kom.Kubectl) error {
 	if err != nil {
 		return err
 	}
+	if namespaced && !stmt.AllNamespace && len(namespaceList) > 1 {
+		// 传入多个命名空间时，过滤掉其他命名空间的事件
+		watcher = newNamespaceFilter(watcher, namespaceList)
+	}
 
 	// 将 watch 赋值给 dest
 	destValue.Elem().Set(reflect.ValueOf(watcher))
 
 	return nil
 }
+
+// namespaceFilter 过滤掉其他命名空间的事件
+// watch.Filter 在消费方停止读取后，发送事件会一直阻塞，Stop 后转发协程无法退出；这里发送时同时等待停止信号
+type namespaceFilter struct {
+	source     watch.Interface
+	namespaces []string
+	result     chan watch.Event
+	stop       chan struct{}
+	once       sync.Once
+}
+
+func newNamespaceFilter(source watch.Interface, namespaces []string) watch.Interface {
+	f := &namespaceFilter{
+		source:     source,
+		namespaces: namespaces,
+		result:     make(chan watch.Event),
+		stop:       make(chan struct{}),
+	}
+	go f.loop()
+	return f
+}
+
+func (f *namespaceFilter) Stop() {
+	f.once.Do(func() {
+		close(f.stop)
+		f.source.Stop()
+	})
+}
+
+func (f *namespaceFilter) ResultChan() <-chan watch.Event {
+	return f.result
+}
+
+func (f *namespaceFilter) loop() {
+	defer close(f.result)
+	for {
+		select {
+		case <-f.stop:
+			return
+		case event, ok := <-f.source.ResultChan():
+			if !ok {
+				return
+			}
+			if !f.keep(event) {
+				continue
+			}
+			select {
+			case f.result <- event:
+			case <-f.stop:
+				return
+			}
+		}
+	}
+}
+
+// keep 错误、书签事件及无法识别命名空间的事件原样转发
+func (f *namespaceFilter) keep(event watch.Event) bool {
+	obj, ok := event.Object.(*unstructured.Unstructured)
+	if !ok || event.Type == watch.Error || event.Type == watch.Bookmark {
+		return true
+	}
+	return slice.Contain(f.namespaces, obj.GetNamespace())
+}
@@ -0,0 +1,181 @@
+package callbacks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/weibaohui/kom/kom"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// WatchQuery 持续查询，按 where 条件计算每个 watch 事件，对象进入、离开结果集或变化时回调
+func WatchQuery(k *kom.Kubectl) error {
+	stmt := k.Statement
+	handler, ok := stmt.Dest.(kom.WatchQueryHandler)
+	if !ok {
+		return fmt.Errorf("stmt.Dest 必须是 kom.WatchQueryHandler")
+	}
+	filter := &stmt.Filter
+	if filter.IsMultiCluster() || len(filter.Joins) > 0 || filter.IsAggregate() {
+		return fmt.Errorf("WatchQuery 不支持跨集群、join 及分组聚合查询")
+	}
+
+	// 子查询只在开始时执行一次
+	resolved, err := k.ResolveSubqueries()
+	if err != nil {
+		return err
+	}
+	original := stmt.Filter
+	stmt.Filter = resolved
+	defer func() { stmt.Filter = original }()
+	plan := k.PushdownPlan()
+	klog.V(6).Infof("watch query pushdown plan:\n%s", plan.Explain())
+
+	opts := metav1.ListOptions{}
+	if len(stmt.ListOptions) > 0 {
+		opts = stmt.ListOptions[0]
+	}
+	q := &watchQuery{
+		k:       k,
+		plan:    plan,
+		handler: handler,
+		matched: map[string]*unstructured.Unstructured{},
+	}
+	if plan.Residual.TimeRelative() {
+		// 条件随时间变化，记录全部对象，定时重新计算
+		q.objects = map[string]*unstructured.Unstructured{}
+	}
+	err = q.run(stmt.Context, watchQueryKubectl(k, plan, plan.ApplyTo(opts)))
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// 主动取消，正常结束
+		return nil
+	}
+	return err
+}
+
+// watchQuery 持续查询的状态
+type watchQuery struct {
+	k       *kom.Kubectl
+	plan    *kom.PushdownPlan
+	handler kom.WatchQueryHandler
+	matched map[string]*unstructured.Unstructured // 当前满足条件的对象
+	objects map[string]*unstructured.Unstructured // 条件含 now() 时，watch 到的全部对象，定时重新计算
+}
+
+// watchQueryKubectl 生成 watch 使用的语句，合并下推的选择器
+// 命名空间条件下推为单个命名空间时只 watch 该命名空间，下推为多个命名空间时 watch 全部命名空间后过滤
+func watchQueryKubectl(k *kom.Kubectl, plan *kom.PushdownPlan, opts metav1.ListOptions) *kom.Kubectl {
+	stmt := *k.Statement
+	stmt.ListOptions = []metav1.ListOptions{opts}
+	if stmt.Namespaced && plan.ByNamespace {
+		stmt.NamespaceList = nil
+		if len(plan.Namespaces) == 1 {
+			stmt.AllNamespace = false
+			stmt.Namespace = plan.Namespaces[0]
+		} else {
+			stmt.AllNamespace = true
+		}
+	}
+	return &kom.Kubectl{ID: k.ID, Statement: &stmt}
+}
+
+// run 使用 ManagedWatcher 先查询当前结果，再持续 watch
+// watch 断开后按退避时间重连，从上次的 resourceVersion 继续，resourceVersion 过期时重新查询并补发事件
+func (q *watchQuery) run(ctx context.Context, tx *kom.Kubectl) error {
+	w := kom.NewManagedWatcher[*unstructured.Unstructured](tx)
+	if err := w.Start(ctx); err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	var recheck <-chan time.Time
+	if q.objects != nil {
+		interval := tx.Statement.RecheckInterval
+		if interval <= 0 {
+			interval = kom.DefaultRecheckInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		recheck = ticker.C
+	}
+	for {
+		select {
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return ctx.Err()
+			}
+			deleted := event.Type == watch.Deleted
+			if q.objects != nil {
+				if deleted {
+					delete(q.objects, watchQueryKey(event.Object))
+				} else {
+					q.objects[watchQueryKey(event.Object)] = event.Object
+				}
+			}
+			if err := q.update(event.Object, deleted); err != nil {
+				return err
+			}
+		case <-recheck:
+			// 对象未变化，按当前时间重新计算，只会触发 enter、leave
+			for _, obj := range q.objects {
+				if err := q.update(obj, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// update 按 where 条件重新计算对象，根据前后是否满足条件触发 enter、leave、change 事件
+func (q *watchQuery) update(obj *unstructured.Unstructured, deleted bool) error {
+	key := watchQueryKey(obj)
+	old, was := q.matched[key]
+	matched := !deleted && q.match(obj)
+
+	event := &kom.WatchQueryEvent{Object: obj, Old: old, Deleted: deleted}
+	switch {
+	case matched && !was:
+		event.Type = kom.WatchQueryEnter
+		q.matched[key] = obj
+	case matched && was:
+		if rv := obj.GetResourceVersion(); rv != "" && rv == old.GetResourceVersion() {
+			// 重新查询时未变化的对象
+			return nil
+		}
+		event.Type = kom.WatchQueryChange
+		q.matched[key] = obj
+	case !matched && was:
+		event.Type = kom.WatchQueryLeave
+		delete(q.matched, key)
+	default:
+		return nil
+	}
+
+	stmt := q.k.Statement
+	if len(stmt.Filter.Projection) > 0 {
+		event.Row = projectRow(obj, stmt.Filter.Projection, stmt.RemoveManagedFields)
+	}
+	klog.V(6).Infof("watch query %s %s", event.Type, key)
+	return q.handler(event)
+}
+
+// match 判断对象是否满足未下推的 where 条件，命名空间条件下推时同时校验命名空间
+func (q *watchQuery) match(obj *unstructured.Unstructured) bool {
+	if q.plan.ByNamespace && !slice.Contain(q.plan.Namespaces, obj.GetNamespace()) {
+		return false
+	}
+	if q.plan.Residual == nil {
+		return true
+	}
+	return evaluateWhere(obj, q.plan.Residual)
+}
+
+func watchQueryKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+}
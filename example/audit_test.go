@@ -0,0 +1,44 @@
+package example
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/weibaohui/kom/audit"
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/mcp/tools"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	err := audit.Register(kom.DefaultCluster(), audit.NewWriterSink(&buf), audit.WithObject())
+	if err != nil {
+		t.Fatalf("Register audit error %v", err)
+	}
+	defer audit.Unregister(kom.DefaultCluster())
+
+	// 按 tools.SetAuthKey 设置的 key 从 Context 中获取用户
+	tools.SetAuthKey("username")
+	ctx := context.WithValue(context.Background(), "username", "admin")
+
+	secret := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "audit-secret", Namespace: "default"},
+		StringData: map[string]string{"password": "123456"},
+	}
+	err = kom.DefaultCluster().WithContext(ctx).Resource(&secret).Create(&secret).Error
+	if err != nil {
+		t.Fatalf("Create error %v", err)
+	}
+	err = kom.DefaultCluster().WithContext(ctx).Resource(&secret).Namespace("default").Name("audit-secret").Delete().Error
+	if err != nil {
+		t.Fatalf("Delete error %v", err)
+	}
+	// 每个操作一行 JSON，Secret 的内容已脱敏
+	t.Logf("audit log:\n%s", buf.String())
+	if bytes.Contains(buf.Bytes(), []byte("123456")) {
+		t.Fatalf("secret data should be redacted")
+	}
+}
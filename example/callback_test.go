@@ -0,0 +1,33 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestCallbackOperation(t *testing.T) {
+	var op kom.Operation
+	get := kom.DefaultCluster().Callback().Get()
+	// After 注册的回调在 kom:get 失败后仍然执行，可获取操作类型、耗时及错误
+	err := get.After("kom:get").Register("example:operation", func(k *kom.Kubectl) error {
+		op = *k.Statement.Operation
+		t.Logf("%s %v %s error=%v", op.Type, op.Callbacks, op.Duration(), op.Error)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Register error %v", err)
+	}
+	defer get.Remove("example:operation")
+
+	var pod v1.Pod
+	err = kom.DefaultCluster().Resource(&pod).Namespace("default").Name("not-exists-pod").Get(&pod).Error
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if op.Type != "get" || !apierrors.IsNotFound(op.Error) {
+		t.Fatalf("after callback should run on failure, got %+v", op)
+	}
+}
@@ -2,6 +2,7 @@ package example
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -191,6 +192,26 @@ func TestListPod(t *testing.T) {
 		t.Errorf("List Pods count,should %d,acctual %d", 1, len(items))
 	}
 }
+func TestListPodConcurrentWithCache(t *testing.T) {
+	// 相同的并发查询只请求一次 API Server，共享结果
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var items []corev1.Pod
+			err := kom.DefaultCluster().
+				Resource(&corev1.Pod{}).
+				Namespace("kube-system").
+				WithCache(5 * time.Second).
+				List(&items).Error
+			if err != nil {
+				t.Errorf("List Error %v\n", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
 func TestListAllNsPod(t *testing.T) {
 	var items []corev1.Pod
 	var pod corev1.Pod
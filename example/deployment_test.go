@@ -3,6 +3,7 @@ package example
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/utils"
@@ -269,6 +270,81 @@ func TestScale(t *testing.T) {
 		t.Errorf("Deployment Scale Clean  error :%v", err)
 	}
 }
+func TestScaleInvalidateCache(t *testing.T) {
+	name := "nginx-scale-cache"
+	item := v1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: v1.DeploymentSpec{
+			Replicas: utils.Int32Ptr(1),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  name,
+							Image: "nginx:1.14.2",
+						},
+					},
+				},
+			},
+		},
+	}
+	err := kom.DefaultCluster().Resource(&item).Create(&item).Error
+	if err != nil {
+		t.Fatalf("Deployment Create(&item) error :%v", err)
+	}
+	defer kom.DefaultCluster().Resource(&item).Namespace("default").Name(name).Delete()
+
+	// 使用长时间缓存查询，写入后应立即看到新的副本数
+	var cached v1.Deployment
+	err = kom.DefaultCluster().Resource(&cached).Namespace("default").Name(name).WithCache(time.Minute).Get(&cached).Error
+	if err != nil {
+		t.Fatalf("Deployment Get error :%v", err)
+	}
+	var list []v1.Deployment
+	err = kom.DefaultCluster().Resource(&cached).Namespace("default").WithCache(time.Minute).List(&list).Error
+	if err != nil {
+		t.Fatalf("Deployment List error :%v", err)
+	}
+
+	replicas := int32(3)
+	err = kom.DefaultCluster().Resource(&item).Namespace("default").Name(name).Ctl().Scale(replicas)
+	if err != nil {
+		t.Fatalf("Deployment Scale error :%v", err)
+	}
+
+	err = kom.DefaultCluster().Resource(&cached).Namespace("default").Name(name).WithCache(time.Minute).Get(&cached).Error
+	if err != nil {
+		t.Fatalf("Deployment Get error :%v", err)
+	}
+	if *cached.Spec.Replicas != replicas {
+		t.Errorf("cached Get replicas expected=%d,actual=%d", replicas, *cached.Spec.Replicas)
+	}
+	err = kom.DefaultCluster().Resource(&cached).Namespace("default").WithCache(time.Minute).List(&list).Error
+	if err != nil {
+		t.Fatalf("Deployment List error :%v", err)
+	}
+	for _, d := range list {
+		if d.Name == name && *d.Spec.Replicas != replicas {
+			t.Errorf("cached List replicas expected=%d,actual=%d", replicas, *d.Spec.Replicas)
+		}
+	}
+}
 func TestReplaceTag(t *testing.T) {
 	name := "nginx-replace-tag"
 	item := v1.Deployment{
@@ -0,0 +1,32 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestInformerCacheList(t *testing.T) {
+	config := kom.DefaultCluster().RestConfig()
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	k, err := kom.Clusters().RegisterByConfigWithID(config, "informer-cache", kom.RegisterInformerCache(gvr), kom.RegisterDisableCRDWatch())
+	if err != nil {
+		t.Fatalf("Register error %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("informer-cache")
+
+	var list []v1.Pod
+	err = k.Resource(&v1.Pod{}).Namespace("kube-system").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("synced: %v, pods in kube-system: %d", k.InformerCache().Synced(gvr), len(list))
+
+	err = k.Sql("select * from pod where metadata.namespace='kube-system' order by metadata.name").List(&list).Error
+	if err != nil {
+		t.Fatalf("Sql error %v", err)
+	}
+	t.Logf("pods in kube-system: %d, resourceVersion %s", len(list), k.InformerCache().ResourceVersion(gvr))
+}
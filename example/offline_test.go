@@ -0,0 +1,158 @@
+package example
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/core/v1"
+)
+
+const offlineManifests = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: default
+  labels:
+    app: web
+status:
+  phase: Running
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-2
+  namespace: default
+  labels:
+    app: web
+status:
+  phase: Pending
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: coredns
+    namespace: kube-system
+    labels:
+      app: dns
+  status:
+    phase: Running
+`
+
+func TestOfflineSql(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pods.yaml"), []byte(offlineManifests), 0644); err != nil {
+		t.Fatalf("write manifests error %v", err)
+	}
+	k, err := kom.Clusters().RegisterOffline("offline-example", dir)
+	if err != nil {
+		t.Fatalf("RegisterOffline error %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("offline-example")
+
+	var list []v1.Pod
+	err = k.Sql("select * from pod where metadata.labels.app='web' and status.phase!='Running'").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "web-2" {
+		t.Fatalf("expected web-2, got %v", list)
+	}
+
+	list = nil
+	err = k.Sql("select * from po order by metadata.name desc limit 2").AllNamespace().List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "web-2" {
+		t.Fatalf("expected web-2 first, got %v", list)
+	}
+
+	var pod v1.Pod
+	err = k.Resource(&pod).Namespace("kube-system").Name("coredns").Get(&pod).Error
+	if err != nil {
+		t.Fatalf("Get error %v", err)
+	}
+
+	err = k.Resource(&pod).Namespace("kube-system").Name("coredns").Delete().Error
+	if err == nil {
+		t.Fatalf("expected delete to fail on offline cluster")
+	}
+}
+
+const offlineTypedManifests = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: old
+  namespace: default
+  creationTimestamp: "2023-06-01T00:00:00Z"
+spec:
+  containers:
+  - name: app
+    image: nginx
+    resources:
+      requests:
+        cpu: 100m
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: new
+  namespace: default
+  creationTimestamp: "2024-06-01T00:00:00Z"
+spec:
+  containers:
+  - name: app
+    image: nginx
+    resources:
+      requests:
+        cpu: 500m
+`
+
+func TestOfflineBoundParamSql(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pods.yaml"), []byte(offlineTypedManifests), 0644); err != nil {
+		t.Fatalf("write manifests error %v", err)
+	}
+	k, err := kom.Clusters().RegisterOffline("offline-bound-param", dir)
+	if err != nil {
+		t.Fatalf("RegisterOffline error %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("offline-bound-param")
+
+	// 比较大小时，字符串参数与字面量一样按时间、Quantity 比较
+	cases := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"select * from pod where metadata.creationTimestamp > ?", []interface{}{"2024-01-01T12:00:00Z"}},
+		{"select * from pod where spec.containers.resources.requests.cpu > ?", []interface{}{"400m"}},
+		{"select * from pod where spec.containers.resources.requests.cpu between ? and ?", []interface{}{"200m", "1"}},
+	}
+	for _, c := range cases {
+		var list []v1.Pod
+		err = k.Sql(c.sql, c.args...).List(&list).Error
+		if err != nil {
+			t.Fatalf("%s List error %v", c.sql, err)
+		}
+		if len(list) != 1 || list[0].Name != "new" {
+			t.Errorf("%s %v expected new, got %v", c.sql, c.args, list)
+		}
+	}
+
+	// 等值比较时，字符串参数按字符串比较
+	var list []v1.Pod
+	err = k.Sql("select * from pod where spec.containers.resources.requests.cpu = ?", "0.5").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("expected no pods for string 0.5, got %v", list)
+	}
+}
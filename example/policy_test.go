@@ -0,0 +1,131 @@
+package example
+
+import (
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/policy"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const examplePolicy = `
+mode: enforce
+default: allow
+rules:
+  - name: no-delete-in-kube-system
+    effect: deny
+    verbs: [delete]
+    namespaces: [kube-system]
+  - name: exec-team-a
+    effect: allow
+    verbs: [exec, stream-exec]
+    namespaces: ["team-a*"]
+  - name: exec-others
+    effect: deny
+    verbs: [exec, stream-exec]
+  - name: readonly-user-x-on-prod
+    effect: deny
+    users: [x]
+    clusters: [prod]
+    verbs: [create, update, patch, delete, exec, stream-exec, port-forward]
+`
+
+func TestPolicyEvaluate(t *testing.T) {
+	p, err := policy.Load([]byte(examplePolicy))
+	if err != nil {
+		t.Fatalf("Load policy error %v", err)
+	}
+	pod := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	cases := []struct {
+		req     policy.Request
+		allowed bool
+	}{
+		{policy.Request{Cluster: "dev", Verb: "delete", GVK: pod, Namespace: "kube-system", Name: "a"}, false},
+		{policy.Request{Cluster: "dev", Verb: "delete", GVK: pod, Namespace: "default", Name: "a"}, true},
+		{policy.Request{Cluster: "dev", Verb: "exec", GVK: pod, Namespace: "team-a-web", Name: "a"}, true},
+		{policy.Request{Cluster: "dev", Verb: "exec", GVK: pod, Namespace: "team-b", Name: "a"}, false},
+		{policy.Request{User: "x", Cluster: "prod", Verb: "patch", GVK: pod, Namespace: "default", Name: "a"}, false},
+		{policy.Request{User: "x", Cluster: "prod", Verb: "list", GVK: pod, Namespace: "default"}, true},
+	}
+	for _, c := range cases {
+		d := p.Evaluate(c.req)
+		if d.Allowed != c.allowed {
+			t.Errorf("%+v expected allowed=%v, got %v by rule %s", c.req, c.allowed, d.Allowed, d.Rule)
+		}
+	}
+}
+
+func TestPolicyDenyDelete(t *testing.T) {
+	p, err := policy.Load([]byte(examplePolicy))
+	if err != nil {
+		t.Fatalf("Load policy error %v", err)
+	}
+	if err = policy.Register(kom.DefaultCluster(), p); err != nil {
+		t.Fatalf("Register policy error %v", err)
+	}
+	defer policy.Unregister(kom.DefaultCluster())
+
+	err = kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("kube-system").Name("not-exists-pod").Delete().Error
+	if !policy.IsPermissionDenied(err) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	t.Logf("%v", err)
+
+	// 测试模式只报告，不拒绝
+	p.Mode = policy.ModeTest
+	var denied []policy.Decision
+	err = policy.Register(kom.DefaultCluster(), p, policy.WithReporter(func(d policy.Decision) {
+		denied = append(denied, d)
+	}))
+	if err != nil {
+		t.Fatalf("Register policy error %v", err)
+	}
+	err = kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("kube-system").Name("not-exists-pod").Delete().Error
+	if policy.IsPermissionDenied(err) || len(denied) != 1 {
+		t.Fatalf("test mode should only report, got err %v, reports %v", err, denied)
+	}
+}
+
+func TestPolicyDenyAllNamespaces(t *testing.T) {
+	p, err := policy.Load([]byte(`
+rules:
+  - name: no-list-in-kube-system
+    effect: deny
+    verbs: [list, watch]
+    namespaces: [kube-system]
+  - name: list-team-a
+    effect: allow
+    verbs: [list]
+    namespaces: ["team-a*"]
+`))
+	if err != nil {
+		t.Fatalf("Load policy error %v", err)
+	}
+	pod := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	// 全部命名空间的查询包含 kube-system，匹配限定命名空间的 deny 规则
+	d := p.Evaluate(policy.Request{Cluster: "dev", Verb: "list", GVK: pod, AllNamespaces: true})
+	if d.Allowed || d.Rule != "no-list-in-kube-system" {
+		t.Fatalf("all namespaces list expected denied by no-list-in-kube-system, got %+v", d)
+	}
+	// 限定命名空间的 allow 规则不匹配全部命名空间
+	p.Rules = p.Rules[1:]
+	if d = p.Evaluate(policy.Request{Cluster: "dev", Verb: "list", GVK: pod, AllNamespaces: true}); d.Rule != "" {
+		t.Fatalf("all namespaces list expected default, got rule %s", d.Rule)
+	}
+
+	p.Rules = []policy.Rule{{Name: "no-list-in-kube-system", Effect: policy.EffectDeny, Verbs: []string{"list"}, Namespaces: []string{"kube-system"}}}
+	if err = policy.Register(kom.DefaultCluster(), p); err != nil {
+		t.Fatalf("Register policy error %v", err)
+	}
+	defer policy.Unregister(kom.DefaultCluster())
+	var pods []v1.Pod
+	err = kom.DefaultCluster().Resource(&v1.Pod{}).AllNamespace().List(&pods).Error
+	if !policy.IsPermissionDenied(err) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	err = kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("default").List(&pods).Error
+	if policy.IsPermissionDenied(err) {
+		t.Fatalf("list default should be allowed, got %v", err)
+	}
+}
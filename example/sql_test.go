@@ -1,10 +1,16 @@
 package example
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -143,3 +149,537 @@ func TestCRD2Sql(t *testing.T) {
 		t.Logf("List Items foreach %s,%s\n", d.GetNamespace(), d.GetName())
 	}
 }
+func TestNestedParenSql(t *testing.T) {
+	// 括号内的 or 先于外层的 and 计算，not 对括号内的整体取反
+	sql := "select * from pod where metadata.namespace='kube-system' and (metadata.name like 'coredns%' or metadata.name like 'etcd%') and not (status.phase='Failed' or status.phase='Unknown')"
+
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql(sql).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, d := range list {
+		if d.Namespace != "kube-system" {
+			t.Errorf("expected namespace kube-system, got %s", d.Namespace)
+		}
+		if !strings.HasPrefix(d.Name, "coredns") && !strings.HasPrefix(d.Name, "etcd") {
+			t.Errorf("unexpected pod %s", d.Name)
+		}
+	}
+	t.Logf("Count %d", len(list))
+}
+func TestSelectColumnsSql(t *testing.T) {
+	sql := "select metadata.name, status.phase as phase, spec.containers.image from pod where metadata.namespace='kube-system'"
+
+	var rows []kom.Row
+	err := kom.DefaultCluster().Sql(sql).List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, row := range rows {
+		if len(row) != 3 {
+			t.Errorf("expected 3 columns, got %v", row)
+		}
+		// 经过数组的字段总是返回数组
+		if _, ok := row["spec.containers.image"].([]interface{}); !ok {
+			t.Errorf("expected image list, got %T %v", row["spec.containers.image"], row["spec.containers.image"])
+		}
+		t.Logf("%v %v %v", row["metadata.name"], row["phase"], row["spec.containers.image"])
+	}
+}
+func TestSelectColumnsChain(t *testing.T) {
+	var rows []map[string]interface{}
+	err := kom.DefaultCluster().From("pod").
+		Select("metadata.namespace as ns", "metadata.name as name").
+		Where("metadata.namespace = ?", "kube-system").
+		List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, row := range rows {
+		t.Logf("%v/%v", row["ns"], row["name"])
+	}
+}
+func TestGroupBySql(t *testing.T) {
+	sql := "select spec.nodeName as node, count(*) as pods, sum(spec.containers.resources.requests.cpu) as cpu from pod group by spec.nodeName having count(*) > 0 order by count(*) desc"
+
+	var rows []kom.Row
+	err := kom.DefaultCluster().Sql(sql).List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	nodes := map[string]bool{}
+	for i, row := range rows {
+		t.Logf("node=%v pods=%v cpu=%v", row["node"], row["pods"], row["cpu"])
+		node := fmt.Sprintf("%v", row["node"])
+		if nodes[node] {
+			t.Errorf("node %s grouped more than once", node)
+		}
+		nodes[node] = true
+		if rowNumber(t, row["pods"]) < 1 {
+			t.Errorf("having count(*) > 0 returned node %s with %v pods", node, row["pods"])
+		}
+		if i > 0 && rowNumber(t, rows[i-1]["pods"]) < rowNumber(t, row["pods"]) {
+			t.Errorf("not sorted by count(*) desc: %v < %v", rows[i-1]["pods"], row["pods"])
+		}
+	}
+}
+func TestGroupByChain(t *testing.T) {
+	var rows []kom.Row
+	err := kom.DefaultCluster().From("pod").
+		Select("status.phase", "count(*) as total").
+		GroupBy("status.phase").
+		Having("count(*) >= ?", 1).
+		List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	// 各分组的数量之和等于 Pod 总数
+	var pods []v1.Pod
+	err = kom.DefaultCluster().From("pod").List(&pods).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	total := 0.0
+	for _, row := range rows {
+		t.Logf("phase=%v total=%v", row["status.phase"], row["total"])
+		total += rowNumber(t, row["total"])
+	}
+	if int(total) != len(pods) {
+		t.Errorf("group totals %v, want %d pods", total, len(pods))
+	}
+}
+
+// rowNumber 结果行中的数值
+func rowNumber(t *testing.T, v interface{}) float64 {
+	n, err := strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+	if err != nil {
+		t.Fatalf("%v is not a number", v)
+	}
+	return n
+}
+func TestJoinSql(t *testing.T) {
+	sql := "select pod.metadata.namespace, pod.metadata.name, node.metadata.name as node from pod join node on pod.spec.nodeName = node.metadata.name where pod.metadata.namespace='kube-system'"
+
+	var rows []kom.Row
+	err := kom.DefaultCluster().Sql(sql).List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	// 每一行的 node 列都来自连接的 Node，并且与 Pod 的 spec.nodeName 一致
+	for _, row := range rows {
+		t.Logf("%v/%v on %v", row["pod.metadata.namespace"], row["pod.metadata.name"], row["node"])
+		if row["pod.metadata.namespace"] != "kube-system" || row["node"] == nil {
+			t.Errorf("unexpected join row %v", row)
+			continue
+		}
+		var pod v1.Pod
+		err = kom.DefaultCluster().Resource(&pod).Namespace("kube-system").Name(fmt.Sprintf("%v", row["pod.metadata.name"])).Get(&pod).Error
+		if err != nil {
+			t.Fatalf("Get error %v", err)
+		}
+		if pod.Spec.NodeName != row["node"] {
+			t.Errorf("pod %s on node %s joined with node %v", pod.Name, pod.Spec.NodeName, row["node"])
+		}
+	}
+}
+func TestLeftJoinOwnerSql(t *testing.T) {
+	// 查询Pod及其所属的ReplicaSet，没有ReplicaSet的Pod也会返回
+	sql := "select p.metadata.namespace as ns, p.metadata.name as pod, rs.metadata.name as rs from pod p left join replicaset rs on p.metadata.ownerReferences.name = rs.metadata.name and p.metadata.namespace = rs.metadata.namespace"
+
+	var rows []kom.Row
+	err := kom.DefaultCluster().Sql(sql).List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+
+	var pods []v1.Pod
+	err = kom.DefaultCluster().Sql("select * from pod").List(&pods).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	owners := map[string][]string{}
+	for _, pod := range pods {
+		key := pod.Namespace + "/" + pod.Name
+		owners[key] = []string{}
+		for _, ref := range pod.OwnerReferences {
+			owners[key] = append(owners[key], ref.Name)
+		}
+	}
+
+	// 每个 Pod 都会返回，rs 列为空或者为 Pod 所属的 ReplicaSet
+	seen := map[string]bool{}
+	for _, row := range rows {
+		t.Logf("pod=%v rs=%v", row["pod"], row["rs"])
+		key := fmt.Sprintf("%v/%v", row["ns"], row["pod"])
+		refs, ok := owners[key]
+		if !ok {
+			t.Errorf("unexpected pod %s", key)
+			continue
+		}
+		seen[key] = true
+		if row["rs"] != nil && !slices.Contains(refs, fmt.Sprintf("%v", row["rs"])) {
+			t.Errorf("pod %s joined with replicaset %v, owners %v", key, row["rs"], refs)
+		}
+	}
+	if len(seen) != len(pods) {
+		t.Errorf("left join returned %d pods, want %d", len(seen), len(pods))
+	}
+}
+func TestMultiOrderSql(t *testing.T) {
+	sql := "select * from pod order by metadata.namespace asc, spec.nodeName desc nulls last, metadata.creationTimestamp desc limit 10 offset 0"
+
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql(sql).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for i := 1; i < len(list); i++ {
+		if list[i-1].Namespace > list[i].Namespace {
+			t.Errorf("namespace not sorted: %s > %s", list[i-1].Namespace, list[i].Namespace)
+		}
+	}
+	for _, d := range list {
+		t.Logf("%s/%s %s", d.Namespace, d.Name, d.Spec.NodeName)
+	}
+}
+func TestPushdownSql(t *testing.T) {
+	sql := "select * from pod where metadata.namespace='kube-system' and metadata.labels.`k8s-app`='kube-dns' and status.phase='Running'"
+
+	k := kom.DefaultCluster().Sql(sql)
+	t.Logf("\n%s", k.PushdownPlan().Explain())
+
+	var list []v1.Pod
+	err := k.List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, d := range list {
+		if d.Namespace != "kube-system" || d.Labels["k8s-app"] != "kube-dns" || d.Status.Phase != v1.PodRunning {
+			t.Errorf("unexpected pod %s/%s", d.Namespace, d.Name)
+		}
+	}
+}
+func TestUpdateDeleteSql(t *testing.T) {
+	yaml := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: sql-write-cm
+  namespace: default
+  labels:
+    env: dev
+data:
+  key: value
+`
+	kom.DefaultCluster().Applier().Apply(yaml)
+
+	var results []kom.SqlResult
+	sql := "update configmap set data.key='updated', metadata.labels.sql='kom' where metadata.namespace='default' and metadata.labels.env='dev' and metadata.name='sql-write-cm'"
+	k := kom.DefaultCluster().Sql(sql).Exec(&results)
+	if k.Error != nil {
+		t.Fatalf("update error %v", k.Error)
+	}
+	if k.Statement.RowsAffected != 1 {
+		t.Errorf("update rows affected %d, want 1", k.Statement.RowsAffected)
+	}
+
+	var cm v1.ConfigMap
+	err := kom.DefaultCluster().Resource(&cm).Namespace("default").Name("sql-write-cm").Get(&cm).Error
+	if err != nil {
+		t.Fatalf("get error %v", err)
+	}
+	if cm.Data["key"] != "updated" || cm.Labels["sql"] != "kom" {
+		t.Errorf("update not applied, data=%v labels=%v", cm.Data, cm.Labels)
+	}
+
+	k = kom.DefaultCluster().Sql("delete from configmap where metadata.namespace='default' and metadata.labels.sql='kom'").Exec(&results)
+	if k.Error != nil {
+		t.Fatalf("delete error %v", k.Error)
+	}
+	for _, r := range results {
+		t.Logf("%s %s/%s", r.Action, r.Namespace, r.Name)
+	}
+
+	// 不带 where 条件的语句会被拒绝
+	err = kom.DefaultCluster().Sql("delete from configmap").Exec(nil).Error
+	if err == nil {
+		t.Errorf("delete without where should fail")
+	}
+}
+func TestRichOperatorSql(t *testing.T) {
+	sql := "select * from pod where metadata.namespace='kube-system' and metadata.name regexp '^(coredns|etcd)' and metadata.deletionTimestamp is null and all(spec.containers.image) not like '%busybox%'"
+
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql(sql).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, d := range list {
+		if !strings.HasPrefix(d.Name, "coredns") && !strings.HasPrefix(d.Name, "etcd") {
+			t.Errorf("unexpected pod %s/%s", d.Namespace, d.Name)
+		}
+	}
+
+	// 不支持的操作符返回错误
+	err = kom.DefaultCluster().Sql("select * from pod where metadata.name <=> 'x'").List(&list).Error
+	if err == nil {
+		t.Errorf("unsupported operator should return error")
+	}
+}
+func TestQuantitySql(t *testing.T) {
+	sql := "select * from pod where spec.containers.resources.requests.memory >= '64Mi' and spec.containers.resources.requests.cpu < 1 order by spec.containers.resources.requests.memory desc"
+
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql(sql).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	// 任一容器满足条件，按第一个容器的 memory 请求降序
+	var last *resource.Quantity
+	for _, d := range list {
+		var memory *resource.Quantity
+		memoryMatched, cpuMatched := false, false
+		for _, c := range d.Spec.Containers {
+			t.Logf("%s/%s %s cpu=%s memory=%s", d.Namespace, d.Name, c.Name, c.Resources.Requests.Cpu(), c.Resources.Requests.Memory())
+			if m, ok := c.Resources.Requests[v1.ResourceMemory]; ok {
+				if memory == nil {
+					memory = &m
+				}
+				memoryMatched = memoryMatched || m.Cmp(resource.MustParse("64Mi")) >= 0
+			}
+			if cpu, ok := c.Resources.Requests[v1.ResourceCPU]; ok {
+				cpuMatched = cpuMatched || cpu.Cmp(resource.MustParse("1")) < 0
+			}
+		}
+		if !memoryMatched || !cpuMatched {
+			t.Errorf("pod %s/%s does not match the quantity conditions", d.Namespace, d.Name)
+		}
+		if memory != nil && last != nil && memory.Cmp(*last) > 0 {
+			t.Errorf("not sorted by memory desc: %s after %s", memory, last)
+		}
+		if memory != nil {
+			last = memory
+		}
+	}
+}
+func TestAllClustersSql(t *testing.T) {
+	sql := "select cluster, count(*) as total from ALL_CLUSTERS.pod where metadata.namespace='kube-system' group by cluster"
+
+	var rows []kom.Row
+	var errs map[string]error
+	err := kom.DefaultCluster().Sql(sql).FillClusterErrors(&errs).List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for id, e := range errs {
+		t.Logf("cluster %s error %v", id, e)
+	}
+	// 每个集群最多一行，cluster 列为集群ID，数量与单独查询该集群一致
+	clusters := kom.Clusters().AllClusters()
+	seen := map[string]bool{}
+	for _, row := range rows {
+		t.Logf("cluster %v pods %v", row["cluster"], row["total"])
+		id, _ := row["cluster"].(string)
+		if _, ok := clusters[id]; !ok || seen[id] {
+			t.Errorf("unexpected cluster column %v", row["cluster"])
+			continue
+		}
+		seen[id] = true
+		var list []v1.Pod
+		err = kom.Cluster(id).Sql("select * from pod where metadata.namespace='kube-system'").List(&list).Error
+		if err != nil {
+			t.Fatalf("List error %v", err)
+		}
+		if int(rowNumber(t, row["total"])) != len(list) {
+			t.Errorf("cluster %s total %v, want %d", id, row["total"], len(list))
+		}
+	}
+}
+func TestSubquerySql(t *testing.T) {
+	sql := "select * from pod where spec.nodeName in (select metadata.name from node where labels('kubernetes.io/os')='linux')"
+
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql(sql).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("pods on linux nodes: %d", len(list))
+
+	// 与先查询 Node 再过滤 Pod 的结果一致
+	var nodes []v1.Node
+	err = kom.DefaultCluster().Sql("select * from node where labels('kubernetes.io/os')='linux'").List(&nodes).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	if len(nodes) == 0 {
+		t.Fatalf("expected linux nodes")
+	}
+	linux := map[string]bool{}
+	for _, n := range nodes {
+		linux[n.Name] = true
+	}
+	var pods []v1.Pod
+	err = kom.DefaultCluster().Sql("select * from pod").List(&pods).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	expected := 0
+	referenced := map[string]bool{}
+	for _, pod := range pods {
+		if linux[pod.Spec.NodeName] {
+			expected++
+		}
+		for _, v := range pod.Spec.Volumes {
+			if v.ConfigMap != nil {
+				referenced[v.ConfigMap.Name] = true
+			}
+		}
+	}
+	for _, pod := range list {
+		if !linux[pod.Spec.NodeName] {
+			t.Errorf("pod %s/%s on non-linux node %s", pod.Namespace, pod.Name, pod.Spec.NodeName)
+		}
+	}
+	if len(list) != expected {
+		t.Errorf("expected %d pods on linux nodes, got %d", expected, len(list))
+	}
+
+	// 未被 Pod 引用的 ConfigMap
+	sql = "select metadata.namespace, metadata.name from configmap where metadata.name not in (select spec.volumes.configMap.name from pod)"
+	var rows []kom.Row
+	err = kom.DefaultCluster().Sql(sql).List(&rows).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, row := range rows {
+		t.Logf("unused configmap %v/%v", row["metadata.namespace"], row["metadata.name"])
+		if referenced[fmt.Sprintf("%v", row["metadata.name"])] {
+			t.Errorf("configmap %v is referenced by a pod", row["metadata.name"])
+		}
+	}
+}
+func TestParamBindingSql(t *testing.T) {
+	// 参数值中的引号不会改变 sql 语义
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql("select * from pod where metadata.namespace=? and metadata.name=?", "kube-system", "x' or '1'='1").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected no pods, got %d", len(list))
+	}
+
+	// 命名参数及列表参数
+	err = kom.DefaultCluster().Sql("select * from pod where metadata.namespace in (?) limit :limit",
+		[]string{"kube-system", "default"}, map[string]interface{}{"limit": 10}).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("pods: %d", len(list))
+
+	// 参数个数不一致
+	err = kom.DefaultCluster().Sql("select * from pod where metadata.name=? and metadata.namespace=?", "abc").List(&list).Error
+	if err == nil {
+		t.Fatalf("expected parameter count error")
+	}
+	t.Logf("parameter error: %v", err)
+}
+func TestFuncSql(t *testing.T) {
+	// 创建超过一小时的 Pod
+	var list []v1.Pod
+	err := kom.DefaultCluster().Sql("select * from pod where metadata.namespace='kube-system' and metadata.creationTimestamp < now() - interval '1 hour'").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("pods older than 1 hour: %d", len(list))
+	for _, d := range list {
+		if d.Namespace != "kube-system" || !d.CreationTimestamp.Time.Before(time.Now().Add(-time.Hour)) {
+			t.Errorf("unexpected pod %s/%s created at %s", d.Namespace, d.Name, d.CreationTimestamp)
+		}
+	}
+
+	// 字符串、长度函数
+	list = nil
+	err = kom.DefaultCluster().Sql("select * from pod where lower(metadata.name) like 'kube%' and len(spec.containers) >= 1").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("pods named kube*: %d", len(list))
+	for _, d := range list {
+		if !strings.HasPrefix(strings.ToLower(d.Name), "kube") {
+			t.Errorf("unexpected pod %s", d.Name)
+		}
+	}
+
+	// len() 按数组元素个数计算，只有一个容器时也不按字符串长度计算
+	list = nil
+	err = kom.DefaultCluster().Sql("select * from pod where len(spec.containers) >= 2").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	for _, d := range list {
+		if len(d.Spec.Containers) < 2 {
+			t.Errorf("pod %s/%s has %d containers", d.Namespace, d.Name, len(d.Spec.Containers))
+		}
+	}
+
+	// key 中含有 . 或 / 的标签
+	list = nil
+	err = kom.DefaultCluster().Resource(&v1.Pod{}).AllNamespace().Where("labels('app.kubernetes.io/name') is not null").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("pods with app.kubernetes.io/name: %d", len(list))
+	for _, d := range list {
+		if _, ok := d.Labels["app.kubernetes.io/name"]; !ok {
+			t.Errorf("pod %s/%s has no app.kubernetes.io/name label", d.Namespace, d.Name)
+		}
+	}
+
+	// 自定义函数
+	err = kom.RegisterSqlFunc("image_repo", func(obj map[string]interface{}, args []interface{}) (interface{}, error) {
+		var repos []interface{}
+		images, ok := args[0].([]interface{})
+		if !ok {
+			images = []interface{}{args[0]}
+		}
+		for _, image := range images {
+			repo, _, _ := strings.Cut(fmt.Sprintf("%v", image), ":")
+			repos = append(repos, repo)
+		}
+		return repos, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterSqlFunc error %v", err)
+	}
+	list = nil
+	err = kom.DefaultCluster().Sql("select * from pod where image_repo(spec.containers.image) like '%coredns'").List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	t.Logf("coredns pods: %d", len(list))
+	for _, d := range list {
+		matched := false
+		for _, c := range d.Spec.Containers {
+			repo, _, _ := strings.Cut(c.Image, ":")
+			matched = matched || strings.HasSuffix(repo, "coredns")
+		}
+		if !matched {
+			t.Errorf("pod %s/%s has no coredns image", d.Namespace, d.Name)
+		}
+	}
+}
+func TestExplainSql(t *testing.T) {
+	explain, err := kom.DefaultCluster().Explain("select metadata.name from pod where metadata.namespace='kube-system' and metadata.nmae like 'coredns%' and spec.containers.resources.limits.memory > '100Mi' order by metadata.creationTimestamp desc")
+	if err != nil {
+		t.Fatalf("Explain error %v", err)
+	}
+	t.Logf("\n%s", explain)
+
+	errs := explain.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 unknown field, got %v", errs)
+	}
+	t.Logf("error: %v", errs[0])
+}
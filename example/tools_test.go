@@ -1,9 +1,13 @@
 package example
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/utils"
+	v1 "k8s.io/api/core/v1"
 )
 
 func TestToolCacheClear(t *testing.T) {
@@ -11,3 +15,44 @@ func TestToolCacheClear(t *testing.T) {
 	kom.DefaultCluster().Tools().ClearCache()
 
 }
+
+func TestToolCacheClearByResource(t *testing.T) {
+	var list []v1.Pod
+	err := kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("kube-system").WithCache(time.Minute).List(&list).Error
+	if err != nil {
+		t.Fatalf("List error %v", err)
+	}
+	// 只清除 pod 的缓存
+	count := kom.DefaultCluster().Resource(&v1.Pod{}).Tools().ClearResourceCache()
+	t.Logf("cleared %d pod cache keys, stats %+v", count, kom.DefaultCluster().Tools().CacheStats())
+}
+
+func TestRedisCache(t *testing.T) {
+	// 使用 Redis 或兼容 Redis 协议的服务作为缓存，多个实例共享
+	addr := os.Getenv("KOM_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("KOM_REDIS_ADDR not set")
+	}
+	cache, err := utils.NewRedisCache(addr, utils.RedisCacheOptions{Prefix: "kom:redis-cache:"})
+	if err != nil {
+		t.Fatalf("NewRedisCache error %v", err)
+	}
+	k, err := kom.Clusters().RegisterByConfigWithID(kom.DefaultCluster().RestConfig(), "redis-cache", kom.RegisterCache(cache), kom.RegisterDisableCRDWatch())
+	if err != nil {
+		t.Fatalf("Register error %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("redis-cache")
+
+	for i := 0; i < 2; i++ {
+		var list []v1.Pod
+		err = k.Resource(&v1.Pod{}).Namespace("kube-system").WithCache(time.Minute).List(&list).Error
+		if err != nil {
+			t.Fatalf("List error %v", err)
+		}
+	}
+	stats := k.Tools().CacheStats()
+	if stats.Hits == 0 {
+		t.Errorf("expected cache hit, stats %+v", stats)
+	}
+	t.Logf("stats %+v", stats)
+}
@@ -1,12 +1,19 @@
 package example
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/weibaohui/kom/kom"
+	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
 )
 
 func TestPodWatch(t *testing.T) {
@@ -40,3 +47,108 @@ func TestPodWatch(t *testing.T) {
 	}
 
 }
+
+func TestPodWatchQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 处于 CrashLoopBackOff 的 Pod 进入、离开结果集时通知
+	sql := "select metadata.namespace, metadata.name from pod where status.containerStatuses.state.waiting.reason='CrashLoopBackOff'"
+	err := kom.DefaultCluster().WithContext(ctx).Sql(sql).
+		WatchQuery(func(e *kom.WatchQueryEvent) error {
+			fmt.Printf("%s Pod [ %v/%v ] deleted=%v\n", e.Type, e.Row["metadata.namespace"], e.Row["metadata.name"], e.Deleted)
+			return nil
+		}).Error
+	if err != nil {
+		t.Fatalf("WatchQuery error %v", err)
+	}
+}
+
+func TestWatchQueryTimeWindow(t *testing.T) {
+	// 模拟 API Server：fresh 刚刚创建，stale 创建于 2020 年，之后 watch 没有任何事件
+	created := time.Now().UTC().Format(time.RFC3339)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintf(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[
+			{"metadata":{"name":"fresh","namespace":"default","resourceVersion":"5","creationTimestamp":"%s"}},
+			{"metadata":{"name":"stale","namespace":"default","resourceVersion":"6","creationTimestamp":"2020-01-01T00:00:00Z"}}]}`, created)
+	}))
+	defer srv.Close()
+	k, err := kom.Clusters().RegisterByConfigWithID(&rest.Config{Host: srv.URL}, "watch-query-window", kom.RegisterDisableCRDWatch())
+	if err != nil {
+		t.Fatalf("Register error %v", err)
+	}
+	defer kom.Clusters().RemoveClusterById("watch-query-window")
+	k.Status().SetAPIResources([]*metav1.APIResource{{Name: "pods", SingularName: "pod", Kind: "Pod", Version: "v1", Namespaced: true}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 对象没有变化，随时间离开 now() 的时间窗口时也触发 leave
+	var events []string
+	err = k.WithContext(ctx).Sql("select * from pod where metadata.creationTimestamp > now() - interval 2 second").
+		WithRecheckInterval(100 * time.Millisecond).
+		WatchQuery(func(e *kom.WatchQueryEvent) error {
+			events = append(events, fmt.Sprintf("%s %s deleted=%v", e.Type, e.Object.GetName(), e.Deleted))
+			if e.Type == kom.WatchQueryLeave {
+				cancel()
+			}
+			return nil
+		}).Error
+	if err != nil {
+		t.Fatalf("WatchQuery error %v", err)
+	}
+	expected := []string{"enter fresh deleted=false", "leave fresh deleted=false"}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatalf("expected events %v, got %v", expected, events)
+	}
+}
+
+func TestPodManagedWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 断开后自动重连，从最后的 resourceVersion 继续，事件对象已转换为 *corev1.Pod
+	w := kom.NewManagedWatcher[*corev1.Pod](kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("kube-system"))
+	if err := w.Start(ctx); err != nil {
+		t.Fatalf("Start managed watcher error %v", err)
+	}
+	defer w.Stop()
+
+	for event := range w.ResultChan() {
+		fmt.Printf("%s Pod [ %s/%s ] relist=%v\n", event.Type, event.Object.Namespace, event.Object.Name, event.Relist)
+	}
+}
+
+func TestDeploymentInformer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 多个命名空间，OnUpdate 传入更新前后的对象及变化的字段
+	err := kom.DefaultCluster().WithContext(ctx).Resource(&v1.Deployment{}).Namespace("default", "kube-system").
+		Informer(kom.InformerHandlers[*v1.Deployment]{
+			OnAdd: func(obj *v1.Deployment) error {
+				fmt.Printf("Added Deployment [ %s/%s ]\n", obj.Namespace, obj.Name)
+				return nil
+			},
+			OnUpdate: func(old, new *v1.Deployment, changes []kom.FieldChange) error {
+				for _, c := range changes {
+					fmt.Printf("Updated Deployment [ %s/%s ] %s\n", new.Namespace, new.Name, c)
+				}
+				return nil
+			},
+			OnDelete: func(obj *v1.Deployment) error {
+				fmt.Printf("Deleted Deployment [ %s/%s ]\n", obj.Namespace, obj.Name)
+				return nil
+			},
+			Diff: true,
+		}).Error
+	if err != nil {
+		t.Fatalf("Informer error %v", err)
+	}
+}
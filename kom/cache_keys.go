@@ -0,0 +1,130 @@
+package kom
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CacheKeyPrefix 资源 Get、List 缓存键的前缀，格式为 resource/group/version/，可用于按资源清除缓存
+func CacheKeyPrefix(gvr schema.GroupVersionResource) string {
+	return fmt.Sprintf("%s/%s/%s/", gvr.Resource, gvr.Group, gvr.Version)
+}
+
+// cacheKeyIndex 按 GVR、命名空间、名称记录缓存键，写操作后据此清除相关缓存
+type cacheKeyIndex struct {
+	mu   sync.Mutex
+	keys map[schema.GroupVersionResource]map[string]cacheKeyRef
+}
+
+// cacheKeyRef 缓存键对应的资源范围
+// name 为空表示列表，namespace 为空表示全部命名空间或集群级资源
+type cacheKeyRef struct {
+	namespace string
+	name      string
+	expire    time.Time
+}
+
+// cacheKeyPruneSize 同一资源记录的缓存键超过该数量时，清理已过期的记录
+const cacheKeyPruneSize = 1024
+
+func newCacheKeyIndex() *cacheKeyIndex {
+	return &cacheKeyIndex{keys: map[schema.GroupVersionResource]map[string]cacheKeyRef{}}
+}
+
+func (c *cacheKeyIndex) track(gvr schema.GroupVersionResource, ns, name, key string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	refs, ok := c.keys[gvr]
+	if !ok {
+		refs = map[string]cacheKeyRef{}
+		c.keys[gvr] = refs
+	}
+	now := time.Now()
+	if len(refs) >= cacheKeyPruneSize {
+		for k, ref := range refs {
+			if now.After(ref.expire) {
+				delete(refs, k)
+			}
+		}
+	}
+	refs[key] = cacheKeyRef{namespace: ns, name: name, expire: now.Add(ttl)}
+}
+
+// affected 获取资源变更后需要清除的缓存键，并移除记录
+// 包括该对象的 Get 缓存、同一命名空间及全部命名空间的 List 缓存，以及记录在空 GVR 下的全局缓存
+func (c *cacheKeyIndex) affected(gvr schema.GroupVersionResource, ns, name string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for _, g := range []schema.GroupVersionResource{gvr, {}} {
+		for k, ref := range c.keys[g] {
+			match := g.Empty() ||
+				(ref.name == "" && (ref.namespace == "" || ref.namespace == ns)) ||
+				(ref.namespace == ns && ref.name == name)
+			if match {
+				keys = append(keys, k)
+				delete(c.keys[g], k)
+			}
+		}
+	}
+	return keys
+}
+
+// cacheTag 缓存键在 TaggedCache 中的标签，与 affected 的匹配规则一致
+// 对象的 Get 缓存记录在 resource/group/version/ns/name 下，List 缓存记录在 resource/group/version/ns/ 下，全局缓存记录在 any 下
+func cacheTag(gvr schema.GroupVersionResource, ns, name string) string {
+	if gvr.Empty() {
+		return "any"
+	}
+	return fmt.Sprintf("%s%s/%s", CacheKeyPrefix(gvr), ns, name)
+}
+
+// TrackCacheKey 记录缓存键对应的资源，该资源写入后清除缓存
+// name 为空表示列表缓存，ns 为空表示全部命名空间或集群级资源；gvr 为空表示任一资源写入后都需要清除
+// 缓存实现了 utils.TaggedCache 时记录到缓存后端，多个实例共享；否则记录在当前进程中
+func (k *Kubectl) TrackCacheKey(gvr schema.GroupVersionResource, ns, name, key string, ttl time.Duration) {
+	cluster := k.parentCluster()
+	if cluster == nil || ttl <= 0 {
+		return
+	}
+	if tc, ok := cluster.Cache.(utils.TaggedCache); ok {
+		tc.Tag(cacheTag(gvr, ns, name), key, ttl)
+		return
+	}
+	if cluster.cacheKeys == nil {
+		return
+	}
+	cluster.cacheKeys.track(gvr, ns, name, key, ttl)
+}
+
+// InvalidateCache 清除资源写入后失效的缓存
+// 由 Create、Update、Patch、Delete 回调在写入成功后调用
+func (k *Kubectl) InvalidateCache(gvr schema.GroupVersionResource, ns, name string) {
+	cluster := k.parentCluster()
+	if cluster == nil || cluster.Cache == nil {
+		return
+	}
+	if tc, ok := cluster.Cache.(utils.TaggedCache); ok {
+		tags := []string{
+			cacheTag(gvr, ns, name),
+			cacheTag(gvr, ns, ""),
+			cacheTag(gvr, "", ""),
+			cacheTag(schema.GroupVersionResource{}, "", ""),
+		}
+		for _, tag := range slices.Compact(tags) {
+			tc.ClearTag(tag)
+		}
+		return
+	}
+	if cluster.cacheKeys == nil {
+		return
+	}
+	for _, key := range cluster.cacheKeys.affected(gvr, ns, name) {
+		cluster.Cache.Del(key)
+	}
+}
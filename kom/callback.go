@@ -3,6 +3,7 @@ package kom
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"k8s.io/klog/v2"
 )
@@ -12,9 +13,25 @@ type callbacks struct {
 	processors map[string]*processor
 }
 
+// Operation 回调链中当前操作的上下文，回调中通过 k.Statement.Operation 获取
+// 执行完成后保留在 Statement 中，嵌套执行的操作结束后恢复为外层操作
+type Operation struct {
+	Type      string      // 操作类型，与处理器名称一致，如 get、list、create
+	StartTime time.Time   // 开始时间
+	Callbacks []string    // 已执行的回调名称，按执行顺序
+	Result    interface{} // 操作结果，默认为 Statement.Dest
+	Error     error       // 第一个返回错误的回调的错误，After 回调可以读取或修改，Execute 返回该错误
+}
+
+// Duration 操作已执行的时间
+func (o *Operation) Duration() time.Duration {
+	return time.Since(o.StartTime)
+}
+
 type processor struct {
+	name      string
 	km        *Kubectl
-	fns       []func(*Kubectl) error
+	fns       []*compiledCallback
 	callbacks []*callback
 }
 type callback struct {
@@ -23,28 +40,42 @@ type callback struct {
 	after     string
 	remove    bool
 	replace   bool
+	always    bool // 通过 After 注册，前面的回调失败后仍然执行
 	handler   func(*Kubectl) error
 	processor *processor
 }
 
+// compiledCallback 排序后的回调
+type compiledCallback struct {
+	name    string
+	always  bool
+	handler func(*Kubectl) error
+}
+
 func (k *Kubectl) initializeCallbacks() *callbacks {
-	return &callbacks{
-		processors: map[string]*processor{
-			"doc":          {km: k},
-			"get":          {km: k},
-			"patch":        {km: k},
-			"create":       {km: k},
-			"update":       {km: k},
-			"delete":       {km: k},
-			"list":         {km: k},
-			"exec":         {km: k},
-			"logs":         {km: k},
-			"watch":        {km: k},
-			"describe":     {km: k},
-			"stream-exec":  {km: k},
-			"port-forward": {km: k},
-		},
+	cs := &callbacks{processors: map[string]*processor{}}
+	for _, name := range []string{
+		"doc", "get", "patch", "create", "update", "delete", "list", "exec",
+		"logs", "watch", "watch-query", "describe", "stream-exec", "port-forward",
+	} {
+		cs.processors[name] = &processor{name: name, km: k}
+	}
+	return cs
+}
+
+// Processor 按操作类型获取处理器，如 get、create、port-forward，不存在时返回 nil
+func (cs *callbacks) Processor(name string) *processor {
+	return cs.processors[name]
+}
+
+// ProcessorNames 全部处理器的名称，按名称排序
+func (cs *callbacks) ProcessorNames() []string {
+	names := make([]string, 0, len(cs.processors))
+	for name := range cs.processors {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
 func (cs *callbacks) Create() *processor {
@@ -87,6 +118,9 @@ func (cs *callbacks) Logs() *processor {
 func (cs *callbacks) Watch() *processor {
 	return cs.processors["watch"]
 }
+func (cs *callbacks) WatchQuery() *processor {
+	return cs.processors["watch-query"]
+}
 func (c *callback) Remove(name string) error {
 	klog.V(4).Infof("removing callback `%s` \n", name)
 	c.name = name
@@ -100,7 +134,19 @@ func (c *callback) Replace(name string, fn func(*Kubectl) error) error {
 	c.name = name
 	c.handler = fn
 	c.replace = true
-	c.processor.callbacks = append(c.processor.callbacks, c)
+	callbacks := c.processor.callbacks
+	for i := len(callbacks) - 1; i >= 0; i-- {
+		if old := callbacks[i]; old.name == name && !old.remove {
+			// 替换时保留原回调的位置、顺序及执行方式
+			if c.before == "" && c.after == "" {
+				c.before, c.after = old.before, old.after
+			}
+			c.always = c.always || old.always
+			callbacks[i] = c
+			return c.processor.compile()
+		}
+	}
+	c.processor.callbacks = append(callbacks, c)
 	return c.processor.compile()
 }
 
@@ -109,8 +155,11 @@ func (c *callback) Before(name string) *callback {
 	return c
 }
 
+// After 在指定回调之后执行，"*" 表示最后执行
+// 通过 After 注册的回调在前面的回调返回错误后仍然执行，可通过 k.Statement.Operation.Error 获取错误
 func (c *callback) After(name string) *callback {
 	c.after = name
+	c.always = true
 	return c
 }
 
@@ -121,6 +170,11 @@ func (c *callback) Register(name string, fn func(*Kubectl) error) error {
 	return c.processor.compile()
 }
 
+// Name 处理器名称，即操作类型
+func (p *processor) Name() string {
+	return p.name
+}
+
 func (p *processor) Get(name string) func(*Kubectl) error {
 	for i := len(p.callbacks) - 1; i >= 0; i-- {
 		if v := p.callbacks[i]; v.name == name && !v.remove {
@@ -138,6 +192,8 @@ func (p *processor) Replace(name string, fn func(*Kubectl) error) error {
 	return (&callback{processor: p}).Replace(name, fn)
 }
 
+// Execute 按顺序执行回调
+// 回调返回错误后，跳过后续回调，只执行通过 After 注册的回调，最终返回 Operation.Error
 func (p *processor) Execute(k *Kubectl) error {
 	// // 执行前做必要检查
 	// if k.Statement.GVR.Empty() {
@@ -145,13 +201,26 @@ func (p *processor) Execute(k *Kubectl) error {
 	// 	return k.Statement.Error
 	// }
 
+	stmt := k.Statement
+	op := &Operation{Type: p.name, StartTime: time.Now(), Result: stmt.Dest}
+	if parent := stmt.Operation; parent != nil {
+		// 回调中嵌套执行的操作，结束后恢复外层操作
+		defer func() {
+			stmt.Operation = parent
+		}()
+	}
+	stmt.Operation = op
+
 	for _, f := range p.fns {
-		err := f(k)
-		if err != nil {
-			return err
+		if op.Error != nil && !f.always {
+			continue
+		}
+		op.Callbacks = append(op.Callbacks, f.name)
+		if err := f.handler(k); err != nil && op.Error == nil {
+			op.Error = err
 		}
 	}
-	return nil
+	return op.Error
 }
 
 func (p *processor) Before(name string) *callback {
@@ -159,7 +228,7 @@ func (p *processor) Before(name string) *callback {
 }
 
 func (p *processor) After(name string) *callback {
-	return &callback{after: name, processor: p}
+	return &callback{after: name, always: true, processor: p}
 }
 
 func (p *processor) Register(name string, fn func(*Kubectl) error) error {
@@ -186,7 +255,7 @@ func (p *processor) compile() (err error) {
 	}
 	return
 }
-func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, err error) {
+func sortCallbacks(cs []*callback) (fns []*compiledCallback, err error) {
 	var (
 		names, sorted []string
 		sortCallback  func(*callback) error
@@ -275,7 +344,7 @@ func sortCallbacks(cs []*callback) (fns []func(*Kubectl) error, err error) {
 
 	for _, name := range sorted {
 		if idx := getRIndex(names, name); !cs[idx].remove {
-			fns = append(fns, cs[idx].handler)
+			fns = append(fns, &compiledCallback{name: name, always: cs[idx].always, handler: cs[idx].handler})
 		}
 	}
 
@@ -14,6 +14,7 @@ import (
 	"github.com/weibaohui/kom/kom/aws"
 	"github.com/weibaohui/kom/kom/describe"
 	"github.com/weibaohui/kom/kom/doc"
+	"github.com/weibaohui/kom/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -45,9 +46,12 @@ type ClusterInst struct {
 	docs               *doc.Docs                    // 文档
 	serverVersion      *version.Info                // 服务器版本
 	describerMap       map[schema.GroupKind]describe.ResourceDescriber
-	Cache              *ristretto.Cache[string, any]
+	Cache              utils.Cache          // 缓存，默认使用 ristretto，可通过 RegisterCache 替换
 	openAPISchema      *openapi_v2.Document // openapi
 	watchCRDCancelFunc context.CancelFunc   // CRD取消方法，用于断开连接的时候停止
+	offline            *OfflineSource       // 离线数据源，离线集群不连接 API Server
+	informerCache      *InformerCache       // informer 列表缓存，注册时开启
+	cacheKeys          *cacheKeyIndex       // 缓存键索引，写操作后清除相关缓存
 
 	// AWS EKS 特定字段
 	AWSAuthProvider    *aws.AuthProvider  // AWS 认证提供者
@@ -227,19 +231,29 @@ func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string
 		c.callbackRegisterFunc(cluster)
 	}
 
-	cacheCfg := params.CacheConfig
-	if cacheCfg == nil {
-		cacheCfg = &ristretto.Config[string, any]{
-			NumCounters: 1e7,     // number of keys to track frequency of (10M).
-			MaxCost:     1 << 30, // maximum cost of cache (1GB).
-			BufferItems: 64,      // number of keys per Get buffer.
+	if params.Cache != nil {
+		cluster.Cache = params.Cache
+	} else {
+		cacheCfg := params.CacheConfig
+		if cacheCfg == nil {
+			cacheCfg = &ristretto.Config[string, any]{
+				NumCounters: 1e7,     // number of keys to track frequency of (10M).
+				MaxCost:     1 << 30, // maximum cost of cache (1GB).
+				BufferItems: 64,      // number of keys per Get buffer.
+			}
+		}
+		cache, err := utils.NewRistrettoCache(cacheCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cache: %w", err)
 		}
+		cluster.Cache = cache
 	}
-	cache, err := ristretto.NewCache(cacheCfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cache: %w", err)
+	cluster.cacheKeys = newCacheKeyIndex()
+
+	// 启动 informer 列表缓存
+	if len(params.InformerGVRs) > 0 {
+		cluster.informerCache = newInformerCache(dynamicClient, params.InformerGVRs)
 	}
-	cluster.Cache = cache
 
 	// 启动CRD监控，有更新的时候，更新APIResources
 	if !params.DisableCRDWatch {
@@ -277,7 +291,7 @@ func (c *ClusterInstances) RemoveClusterById(id string) {
 			}
 		}
 
-		// 释放 ristretto.Cache 资源
+		// 释放缓存资源
 		if cluster.Cache != nil {
 			cluster.Cache.Close()
 			cluster.Cache = nil
@@ -292,6 +306,12 @@ func (c *ClusterInstances) RemoveClusterById(id string) {
 		cluster.serverVersion = nil
 		cluster.describerMap = nil
 		cluster.openAPISchema = nil
+		cluster.offline = nil
+		cluster.cacheKeys = nil
+		if cluster.informerCache != nil {
+			cluster.informerCache.Stop()
+			cluster.informerCache = nil
+		}
 		if cluster.watchCRDCancelFunc != nil {
 			cluster.watchCRDCancelFunc()
 		}
@@ -0,0 +1,179 @@
+package kom
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/klog/v2"
+)
+
+// informerWaitTimeout 等待 informer 同步到指定 resourceVersion 的最长时间，超时后改为请求 API Server
+const informerWaitTimeout = 3 * time.Second
+
+// InformerCache 基于 SharedInformer 的列表缓存
+// 注册集群时通过 RegisterInformerCache 指定需要缓存的资源，List、Get 直接从 informer 的索引中读取，
+// informer 未完成同步或无法满足请求的 resourceVersion 时，仍然请求 API Server
+type InformerCache struct {
+	factory   dynamicinformer.DynamicSharedInformerFactory
+	informers map[schema.GroupVersionResource]informers.GenericInformer
+	cancel    context.CancelFunc
+}
+
+// newInformerCache 为指定的资源创建 informer 并启动
+func newInformerCache(client dynamic.Interface, gvrs []schema.GroupVersionResource) *InformerCache {
+	c := &InformerCache{
+		factory:   dynamicinformer.NewDynamicSharedInformerFactory(client, 0),
+		informers: map[schema.GroupVersionResource]informers.GenericInformer{},
+	}
+	for _, gvr := range gvrs {
+		c.informers[gvr] = c.factory.ForResource(gvr)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.factory.Start(ctx.Done())
+	return c
+}
+
+// Stop 停止全部 informer，并等待其退出
+func (c *InformerCache) Stop() {
+	c.cancel()
+	c.factory.Shutdown()
+}
+
+// Has 是否缓存了该资源
+func (c *InformerCache) Has(gvr schema.GroupVersionResource) bool {
+	_, ok := c.informers[gvr]
+	return ok
+}
+
+// Synced 该资源的 informer 是否已完成首次同步
+func (c *InformerCache) Synced(gvr schema.GroupVersionResource) bool {
+	informer, ok := c.informers[gvr]
+	return ok && informer.Informer().HasSynced()
+}
+
+// ResourceVersion 该资源 informer 最近一次同步到的 resourceVersion
+func (c *InformerCache) ResourceVersion(gvr schema.GroupVersionResource) string {
+	informer, ok := c.informers[gvr]
+	if !ok {
+		return ""
+	}
+	return informer.Informer().LastSyncResourceVersion()
+}
+
+// List 从索引中获取资源列表，ns 为空表示全部命名空间
+// resourceVersion 为空或 "0" 时直接返回缓存中的数据，
+// 否则等待 informer 同步到不低于该版本后返回，保证读到该版本之后的数据。
+// 第二个返回值为 false 表示缓存无法提供，调用方应请求 API Server
+func (c *InformerCache) List(ctx context.Context, gvr schema.GroupVersionResource, ns string, resourceVersion string) ([]*unstructured.Unstructured, bool) {
+	informer, ok := c.ready(ctx, gvr, resourceVersion)
+	if !ok {
+		return nil, false
+	}
+	var objs []runtime.Object
+	var err error
+	if ns == "" {
+		objs, err = informer.Lister().List(labels.Everything())
+	} else {
+		objs, err = informer.Lister().ByNamespace(ns).List(labels.Everything())
+	}
+	if err != nil {
+		klog.V(6).Infof("informer cache list %s error %v", gvr.String(), err)
+		return nil, false
+	}
+	items := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			// 返回副本，避免后续处理修改索引中的对象
+			items = append(items, u.DeepCopy())
+		}
+	}
+	return items, true
+}
+
+// Get 从索引中获取单个资源，不存在时返回 NotFound 错误
+// 第二个返回值为 false 表示缓存无法提供，调用方应请求 API Server
+func (c *InformerCache) Get(ctx context.Context, gvr schema.GroupVersionResource, ns string, name string) (*unstructured.Unstructured, bool, error) {
+	informer, ok := c.ready(ctx, gvr, "")
+	if !ok {
+		return nil, false, nil
+	}
+	var obj runtime.Object
+	var err error
+	if ns == "" {
+		obj, err = informer.Lister().Get(name)
+	} else {
+		obj, err = informer.Lister().ByNamespace(ns).Get(name)
+	}
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, true, apierrors.NewNotFound(gvr.GroupResource(), name)
+		}
+		return nil, false, nil
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, false, nil
+	}
+	return u.DeepCopy(), true, nil
+}
+
+// ready 获取已同步并满足 resourceVersion 要求的 informer
+func (c *InformerCache) ready(ctx context.Context, gvr schema.GroupVersionResource, resourceVersion string) (informers.GenericInformer, bool) {
+	informer, ok := c.informers[gvr]
+	if !ok || !informer.Informer().HasSynced() {
+		return nil, false
+	}
+	if resourceVersion == "" || resourceVersion == "0" {
+		return informer, true
+	}
+	want, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, informerWaitTimeout)
+	defer cancel()
+	err = wait.PollUntilContextCancel(ctx, 50*time.Millisecond, true, func(context.Context) (bool, error) {
+		got, err := strconv.ParseUint(informer.Informer().LastSyncResourceVersion(), 10, 64)
+		return err == nil && got >= want, nil
+	})
+	if err != nil {
+		klog.V(6).Infof("informer cache %s not synced to resourceVersion %s", gvr.String(), resourceVersion)
+		return nil, false
+	}
+	return informer, true
+}
+
+// InformerCache 当前集群的 informer 缓存，未开启时返回 nil
+func (c *ClusterInst) InformerCache() *InformerCache {
+	return c.informerCache
+}
+
+// InformerCache 当前集群的 informer 缓存，未开启时返回 nil
+func (k *Kubectl) InformerCache() *InformerCache {
+	cluster := k.parentCluster()
+	if cluster == nil {
+		return nil
+	}
+	return cluster.informerCache
+}
+
+// CanServe 判断该资源的 List 请求能否由缓存提供
+// 分页、精确匹配 resourceVersion 的请求需要 API Server 处理
+func (c *InformerCache) CanServe(gvr schema.GroupVersionResource, opts metav1.ListOptions) bool {
+	if !c.Has(gvr) || opts.Limit > 0 || opts.Continue != "" {
+		return false
+	}
+	return opts.ResourceVersionMatch == "" || opts.ResourceVersionMatch == metav1.ResourceVersionMatchNotOlderThan
+}
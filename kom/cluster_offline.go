@@ -0,0 +1,264 @@
+package kom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/ristretto/v2"
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+)
+
+// OfflineSource 离线数据源
+// 从 YAML/JSON 清单中加载资源对象，按 GVK 分表保存在内存中，不需要连接 API Server
+type OfflineSource struct {
+	mu     sync.RWMutex
+	tables map[schema.GroupVersionKind][]*unstructured.Unstructured
+}
+
+// 常用内置资源的简称，与 kubectl 保持一致
+var offlineShortNames = map[string][]string{
+	"ConfigMap":                {"cm"},
+	"CronJob":                  {"cj"},
+	"CustomResourceDefinition": {"crd", "crds"},
+	"DaemonSet":                {"ds"},
+	"Deployment":               {"deploy"},
+	"Endpoints":                {"ep"},
+	"Event":                    {"ev"},
+	"HorizontalPodAutoscaler":  {"hpa"},
+	"Ingress":                  {"ing"},
+	"Namespace":                {"ns"},
+	"Node":                     {"no"},
+	"PersistentVolume":         {"pv"},
+	"PersistentVolumeClaim":    {"pvc"},
+	"Pod":                      {"po"},
+	"ReplicaSet":               {"rs"},
+	"Service":                  {"svc"},
+	"ServiceAccount":           {"sa"},
+	"StatefulSet":              {"sts"},
+	"StorageClass":             {"sc"},
+}
+
+// NewOfflineSource 创建离线数据源，从文件或目录中加载 .yaml、.yml、.json 清单
+// 目录会递归加载，一个文件中可以包含多个 YAML 文档，kind 为 List 的对象会展开其中的 items
+func NewOfflineSource(paths ...string) (*OfflineSource, error) {
+	s := &OfflineSource{tables: map[schema.GroupVersionKind][]*unstructured.Unstructured{}}
+	for _, path := range paths {
+		if err := s.LoadPath(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// LoadPath 加载文件或目录
+func (s *OfflineSource) LoadPath(path string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			if p != path {
+				// 目录中的其他文件忽略，直接指定的文件按内容加载
+				return nil
+			}
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err = s.Load(data); err != nil {
+			return fmt.Errorf("load %s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// Load 加载 YAML/JSON 内容，支持多文档
+func (s *OfflineSource) Load(data []byte) error {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if len(obj) == 0 {
+			// 空文档
+			continue
+		}
+		if err := s.Add(&unstructured.Unstructured{Object: obj}); err != nil {
+			return err
+		}
+	}
+}
+
+// Add 添加资源对象，kind 为 List 的对象会展开其中的 items
+func (s *OfflineSource) Add(objs ...*unstructured.Unstructured) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, obj := range objs {
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return err
+			}
+			for i := range list.Items {
+				if err = s.add(&list.Items[i]); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := s.add(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OfflineSource) add(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return fmt.Errorf("object %s/%s has no apiVersion or kind", obj.GetNamespace(), obj.GetName())
+	}
+	s.tables[gvk] = append(s.tables[gvk], obj)
+	return nil
+}
+
+// Items 获取 GVK 对应表中的全部对象
+func (s *OfflineSource) Items(gvk schema.GroupVersionKind) []*unstructured.Unstructured {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tables[gvk]
+}
+
+// GVKs 已加载的资源类型
+func (s *OfflineSource) GVKs() []schema.GroupVersionKind {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var gvks []schema.GroupVersionKind
+	for gvk := range s.tables {
+		gvks = append(gvks, gvk)
+	}
+	sort.Slice(gvks, func(i, j int) bool {
+		return gvks[i].String() < gvks[j].String()
+	})
+	return gvks
+}
+
+// APIResources 按已加载的表生成 APIResource，用于 sql 中表名的解析
+// 资源名按 kind 推断复数形式，表中有任一对象带有命名空间即视为命名空间级资源
+func (s *OfflineSource) APIResources() []*metav1.APIResource {
+	var resources []*metav1.APIResource
+	for _, gvk := range s.GVKs() {
+		plural, singular := meta.UnsafeGuessKindToResource(gvk)
+		namespaced := false
+		for _, obj := range s.Items(gvk) {
+			if obj.GetNamespace() != "" {
+				namespaced = true
+				break
+			}
+		}
+		resources = append(resources, &metav1.APIResource{
+			Name:         plural.Resource,
+			SingularName: singular.Resource,
+			Namespaced:   namespaced,
+			Group:        gvk.Group,
+			Version:      gvk.Version,
+			Kind:         gvk.Kind,
+			ShortNames:   offlineShortNames[gvk.Kind],
+			Verbs:        metav1.Verbs{"get", "list"},
+		})
+	}
+	return resources
+}
+
+// RegisterOffline 注册离线集群，从文件或目录中加载 YAML/JSON 清单
+// 离线集群只支持 Get、List 及 Sql 查询，可以与其他集群一起参与跨集群查询
+//
+//	kom.Clusters().RegisterOffline("archive", "./dump")
+//	kom.Cluster("archive").Sql("select * from pod where status.phase!='Running'").List(&list)
+func (c *ClusterInstances) RegisterOffline(id string, paths ...string) (*Kubectl, error) {
+	source, err := NewOfflineSource(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return c.RegisterOfflineSource(id, source)
+}
+
+// RegisterOfflineSource 使用离线数据源注册离线集群，同一ID已注册时返回已有的集群
+func (c *ClusterInstances) RegisterOfflineSource(id string, source *OfflineSource) (*Kubectl, error) {
+	if source == nil {
+		return nil, fmt.Errorf("offline source is nil")
+	}
+	if value, exists := c.clusters.Load(id); exists {
+		if cluster := value.(*ClusterInst); cluster.Kubectl != nil {
+			return cluster.Kubectl, nil
+		}
+	}
+	klog.V(2).Infof("k8s init 离线集群：%s\n", id)
+
+	k := &Kubectl{ID: id, clone: 1}
+	k.Statement = &Statement{
+		Context: context.Background(),
+		Kubectl: k,
+	}
+	cache, err := utils.NewRistrettoCache(&ristretto.Config[string, any]{
+		NumCounters: 1e4,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache: %w", err)
+	}
+	cluster := &ClusterInst{
+		ID:           id,
+		Kubectl:      k,
+		Cache:        cache,
+		cacheKeys:    newCacheKeyIndex(),
+		offline:      source,
+		apiResources: source.APIResources(),
+	}
+	c.clusters.Store(id, cluster)
+	cluster.callbacks = k.initializeCallbacks()
+	if c.callbackRegisterFunc != nil {
+		c.callbackRegisterFunc(cluster)
+	}
+	return k, nil
+}
+
+// OfflineSource 离线集群的数据源，在线集群返回 nil
+func (c *ClusterInst) OfflineSource() *OfflineSource {
+	return c.offline
+}
+
+// OfflineSource 当前集群的离线数据源，在线集群返回 nil
+func (k *Kubectl) OfflineSource() *OfflineSource {
+	cluster := k.parentCluster()
+	if cluster == nil {
+		return nil
+	}
+	return cluster.offline
+}
@@ -568,10 +568,12 @@ func (d *node) PodCount() (total, used, available int) {
 
 // getNodeWithCache 获取节点的方法，带缓存
 func (d *node) getNodeWithCache(cacheTime time.Duration) (*corev1.Node, error) {
+	cacheKey := fmt.Sprintf("getNodeWithCache/%s", d.kubectl.Statement.Name)
+	ttl := d.getCacheTTL(10 * time.Second)
 	node, err := utils.GetOrSetCache(
 		d.kubectl.ClusterCache(),
-		fmt.Sprintf("getNodeWithCache/%s", d.kubectl.Statement.Name),
-		d.getCacheTTL(10*time.Second),
+		cacheKey,
+		ttl,
 		func() (*corev1.Node, error) {
 			var n *corev1.Node
 			err := d.kubectl.newInstance().WithContext(d.kubectl.Statement.Context).Resource(&corev1.Node{}).
@@ -583,6 +585,10 @@ func (d *node) getNodeWithCache(cacheTime time.Duration) (*corev1.Node, error) {
 			return n, nil
 		},
 	)
+	if err == nil {
+		// 节点更新后清除
+		d.kubectl.TrackCacheKey(corev1.SchemeGroupVersion.WithResource("nodes"), "", d.kubectl.Statement.Name, cacheKey, ttl)
+	}
 	return node, err
 
 }
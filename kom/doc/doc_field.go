@@ -1,7 +1,6 @@
 package doc
 
 import (
-	"fmt"
 	"strings"
 
 	openapi_v2 "github.com/google/gnostic-models/openapiv2"
@@ -17,20 +16,12 @@ type DocField struct {
 
 func (k *DocField) GetApiDocV2(field string) string {
 	klog.V(8).Infof("GetApiDocV2 %s", field)
-	startPoint := ""
 	// the path must be formated like "path1.path2.path3"
 	paths := strings.Split(field, ".")
-	group := strings.Split(k.ApiVersion.Group, ".")
 	definitions := k.OpenapiSchema.GetDefinitions().GetAdditionalProperties()
 
 	// extract the startpoint by searching the highest leaf corresponding to the requested group qnd kind
-	for _, prop := range definitions {
-		if strings.HasSuffix(prop.GetName(), fmt.Sprintf("%s.%s.%s", group[0], k.ApiVersion.Version, k.Kind)) {
-			startPoint = prop.GetName()
-
-			break
-		}
-	}
+	startPoint := k.startPoint()
 
 	// recursively parse the definitions to find the description of the latest part of the given path
 	description := k.recursePath(definitions, startPoint, paths)
@@ -0,0 +1,180 @@
+package doc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
+	"github.com/weibaohui/kom/utils"
+)
+
+// FieldPath 字段路径的校验结果
+type FieldPath struct {
+	Path        string   `json:"path"`
+	Valid       bool     `json:"valid"`
+	Type        string   `json:"type,omitempty"`        // 字段类型，如 string、integer、boolean、object、array
+	Format      string   `json:"format,omitempty"`      // 字段格式，如 date-time、int-or-string、quantity
+	Array       bool     `json:"array,omitempty"`       // 路径经过数组，字段可能有多个值
+	Unknown     string   `json:"unknown,omitempty"`     // 第一个不存在的字段
+	Suggestions []string `json:"suggestions,omitempty"` // 与不存在的字段相近的字段路径
+}
+
+// ResolvePath 按照 OpenAPI Schema 校验字段路径，如 metadata.name、status.addresses[type=InternalIP].address
+// map 类型字段（如 metadata.labels）的 key 不做校验，未定义结构的字段（如 CRD 中 x-kubernetes-preserve-unknown-fields）不再向下校验
+func (k *DocField) ResolvePath(field string) *FieldPath {
+	result := &FieldPath{Path: field}
+	definitions := map[string]*openapi_v2.Schema{}
+	for _, prop := range k.OpenapiSchema.GetDefinitions().GetAdditionalProperties() {
+		definitions[prop.GetName()] = prop.GetValue()
+	}
+	current := definitions[k.startPoint()]
+	if current == nil {
+		// 没有找到资源定义，无法校验
+		result.Valid = true
+		return result
+	}
+
+	paths := strings.Split(field, ".")
+	prefix := ""
+	for i, name := range paths {
+		current, result.Array = resolveSchema(definitions, current, result.Array)
+		// 去掉数组筛选条件，如 addresses[type=InternalIP]
+		if idx := strings.Index(name, "["); idx > 0 {
+			name = name[:idx]
+		}
+
+		properties := current.GetProperties().GetAdditionalProperties()
+		if len(properties) == 0 {
+			if value := current.GetAdditionalProperties().GetSchema(); value != nil {
+				// map 类型，key 可以包含 .，如 metadata.labels.app.kubernetes.io/name，value 为基本类型时剩余部分都作为 key
+				current = value
+				if v, _ := resolveSchema(definitions, current, false); schemaType(v) != "object" {
+					current = v
+					break
+				}
+				prefix = strings.Join(paths[:i+1], ".") + "."
+				continue
+			}
+			if schemaType(current) == "object" || schemaType(current) == "" {
+				// 未定义结构的对象，不再向下校验
+				result.Valid = true
+				result.Type = "object"
+				return result
+			}
+			// 基本类型不能再有下级字段
+			result.Unknown = prefix + name
+			return result
+		}
+
+		var next *openapi_v2.Schema
+		var names []string
+		for _, prop := range properties {
+			if prop.GetName() == name {
+				next = prop.GetValue()
+				break
+			}
+			names = append(names, prop.GetName())
+		}
+		if next == nil {
+			result.Unknown = prefix + name
+			for _, s := range suggest(name, names) {
+				result.Suggestions = append(result.Suggestions, prefix+strings.Join(append([]string{s}, paths[i+1:]...), "."))
+			}
+			return result
+		}
+		current = next
+		prefix = strings.Join(paths[:i+1], ".") + "."
+	}
+
+	current, _ = resolveSchema(definitions, current, false)
+	result.Valid = true
+	result.Type = schemaType(current)
+	result.Format = current.GetFormat()
+	if strings.HasSuffix(current.GetXRef(), "resource.Quantity") {
+		result.Format = "quantity"
+	}
+	return result
+}
+
+// startPoint 查找资源对应的定义名称
+func (k *DocField) startPoint() string {
+	group := strings.Split(k.ApiVersion.Group, ".")
+	for _, prop := range k.OpenapiSchema.GetDefinitions().GetAdditionalProperties() {
+		if strings.HasSuffix(prop.GetName(), fmt.Sprintf("%s.%s.%s", group[0], k.ApiVersion.Version, k.Kind)) {
+			return prop.GetName()
+		}
+	}
+	return ""
+}
+
+// resolveSchema 展开 $ref 引用，数组类型展开为元素类型并标记经过了数组
+// 保留引用的定义名称，便于识别 Quantity 等类型
+func resolveSchema(definitions map[string]*openapi_v2.Schema, s *openapi_v2.Schema, array bool) (*openapi_v2.Schema, bool) {
+	for i := 0; i < 32 && s != nil; i++ {
+		ref := s.GetXRef()
+		if ref == "" {
+			for _, item := range s.GetAllOf() {
+				if item.GetXRef() != "" {
+					ref = item.GetXRef()
+					break
+				}
+			}
+		}
+		if ref != "" {
+			name := ref[strings.LastIndex(ref, "/")+1:]
+			def, ok := definitions[name]
+			if !ok {
+				return s, array
+			}
+			if schemaType(def) != "object" && schemaType(def) != "array" {
+				// 基本类型的定义，如 Quantity、Time，保留引用名称
+				return &openapi_v2.Schema{XRef: ref, Type: def.GetType(), Format: def.GetFormat()}, array
+			}
+			s = def
+			continue
+		}
+		if items := s.GetItems().GetSchema(); schemaType(s) == "array" && len(items) > 0 {
+			s = items[0]
+			array = true
+			continue
+		}
+		return s, array
+	}
+	return s, array
+}
+
+// schemaType 字段类型
+func schemaType(s *openapi_v2.Schema) string {
+	if v := s.GetType().GetValue(); len(v) > 0 {
+		return v[0]
+	}
+	if len(s.GetProperties().GetAdditionalProperties()) > 0 || s.GetAdditionalProperties() != nil {
+		return "object"
+	}
+	return ""
+}
+
+// suggest 查找相近的字段名，按编辑距离排序，最多返回3个
+func suggest(name string, candidates []string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+	var matches []scored
+	lower := strings.ToLower(name)
+	for _, c := range candidates {
+		d := utils.EditDistance(lower, strings.ToLower(c))
+		if d <= max(2, len(name)/3) || strings.HasPrefix(strings.ToLower(c), lower) {
+			matches = append(matches, scored{c, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+	var result []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		result = append(result, matches[i].name)
+	}
+	return result
+}
@@ -0,0 +1,251 @@
+package kom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// FieldChange 对象更新前后变化的字段
+type FieldChange struct {
+	Path string // 字段路径，如 spec.replicas、spec.template.spec.containers[0].image
+	Old  any    // 更新前的值，新增字段时为 nil
+	New  any    // 更新后的值，删除字段时为 nil
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, formatFieldValue(c.Old), formatFieldValue(c.New))
+}
+
+func formatFieldValue(v any) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// InformerHandler Informer 的事件处理，使用 InformerHandlers 创建
+type InformerHandler interface {
+	check() error
+	handle(eventType watch.EventType, old, obj *unstructured.Unstructured) error
+}
+
+// InformerHandlers Informer 的事件处理函数，T 为回调中对象的类型，如 *corev1.Pod
+// 使用 *unstructured.Unstructured 时不做转换。处理函数返回错误时停止 Informer
+type InformerHandlers[T any] struct {
+	OnAdd    func(obj T) error
+	OnUpdate func(old, new T, changes []FieldChange) error // 开启 Diff 时 changes 为变化的字段，否则为 nil
+	OnDelete func(obj T) error
+	Diff     bool // 是否计算 OnUpdate 前后变化的字段，忽略 metadata.resourceVersion、metadata.managedFields
+}
+
+func (h InformerHandlers[T]) check() error {
+	if h.OnAdd == nil && h.OnUpdate == nil && h.OnDelete == nil {
+		return fmt.Errorf("Informer 需要传入事件处理函数")
+	}
+	var zero T
+	if reflect.TypeOf(zero) == nil || reflect.TypeOf(zero).Kind() != reflect.Ptr {
+		return fmt.Errorf("Informer 的对象类型必须为指针，如 *corev1.Pod")
+	}
+	return nil
+}
+
+func (h InformerHandlers[T]) handle(eventType watch.EventType, old, obj *unstructured.Unstructured) error {
+	switch eventType {
+	case watch.Added:
+		if h.OnAdd == nil {
+			return nil
+		}
+		o, err := convertUnstructured[T](obj)
+		if err != nil {
+			return err
+		}
+		return h.OnAdd(o)
+	case watch.Modified:
+		if h.OnUpdate == nil {
+			return nil
+		}
+		o, err := convertUnstructured[T](old)
+		if err != nil {
+			return err
+		}
+		n, err := convertUnstructured[T](obj)
+		if err != nil {
+			return err
+		}
+		var changes []FieldChange
+		if h.Diff {
+			changes = DiffFields(old, obj)
+		}
+		return h.OnUpdate(o, n, changes)
+	case watch.Deleted:
+		if h.OnDelete == nil {
+			return nil
+		}
+		o, err := convertUnstructured[T](obj)
+		if err != nil {
+			return err
+		}
+		return h.OnDelete(o)
+	}
+	return nil
+}
+
+// Informer 以事件处理函数的方式 watch 资源
+// 先查询当前对象并调用 OnAdd，之后对象新增、更新、删除时分别调用 OnAdd、OnUpdate、OnDelete，OnUpdate 同时传入更新前的对象。
+// 基于 ManagedWatcher，断开后自动重连；传入多个命名空间时只处理这些命名空间中的对象。
+// 调用会阻塞，直到 Context 取消或处理函数返回错误。
+//
+//	err := kom.DefaultCluster().WithContext(ctx).Resource(&v1.Deployment{}).Namespace("prod", "test").
+//		Informer(kom.InformerHandlers[*v1.Deployment]{
+//			OnUpdate: func(old, new *v1.Deployment, changes []kom.FieldChange) error {
+//				for _, c := range changes {
+//					fmt.Println(new.Name, c) // nginx spec.replicas: 2 -> 3
+//				}
+//				return nil
+//			},
+//			Diff: true,
+//		}).Error
+func (k *Kubectl) Informer(handler InformerHandler) *Kubectl {
+	tx := k.getInstance()
+	if tx.Error != nil {
+		return tx
+	}
+	if handler == nil {
+		tx.Error = fmt.Errorf("Informer 需要传入事件处理函数")
+		return tx
+	}
+	if tx.Error = handler.check(); tx.Error != nil {
+		return tx
+	}
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	w := NewManagedWatcher[*unstructured.Unstructured](tx)
+	if tx.Error = w.Start(ctx); tx.Error != nil {
+		return tx
+	}
+	defer w.Stop()
+
+	// 已知对象，用于 OnUpdate 传入更新前的对象
+	known := map[string]*unstructured.Unstructured{}
+	for event := range w.ResultChan() {
+		key := managedWatchKey(event.Object)
+		old, exists := known[key]
+		eventType := event.Type
+		switch eventType {
+		case watch.Deleted:
+			delete(known, key)
+		case watch.Added, watch.Modified:
+			known[key] = event.Object
+			if eventType == watch.Modified && !exists {
+				eventType = watch.Added
+			} else if eventType == watch.Added && exists {
+				eventType = watch.Modified
+			}
+		}
+		if err := handler.handle(eventType, old, event.Object); err != nil {
+			tx.Error = err
+			return tx
+		}
+	}
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		tx.Error = err
+	}
+	return tx
+}
+
+// DiffFields 比较对象前后变化的字段，按路径排序
+// 忽略 metadata.resourceVersion、metadata.managedFields，列表按下标比较
+func DiffFields(old, new *unstructured.Unstructured) []FieldChange {
+	var changes []FieldChange
+	var o, n map[string]interface{}
+	if old != nil {
+		o = old.Object
+	}
+	if new != nil {
+		n = new.Object
+	}
+	diffValue("", o, n, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// diffIgnoreFields 不参与比较的字段
+var diffIgnoreFields = map[string]bool{
+	"metadata.resourceVersion": true,
+	"metadata.managedFields":   true,
+}
+
+func diffValue(path string, old, new interface{}, changes *[]FieldChange) {
+	if diffIgnoreFields[path] {
+		return
+	}
+	om, oIsMap := old.(map[string]interface{})
+	nm, nIsMap := new.(map[string]interface{})
+	if oIsMap && nIsMap {
+		for key, ov := range om {
+			diffValue(joinFieldPath(path, key), ov, nm[key], changes)
+		}
+		for key, nv := range nm {
+			if _, ok := om[key]; !ok {
+				diffValue(joinFieldPath(path, key), nil, nv, changes)
+			}
+		}
+		return
+	}
+	ol, oIsList := old.([]interface{})
+	nl, nIsList := new.([]interface{})
+	if oIsList && nIsList {
+		for i := 0; i < len(ol) || i < len(nl); i++ {
+			var ov, nv interface{}
+			if i < len(ol) {
+				ov = ol[i]
+			}
+			if i < len(nl) {
+				nv = nl[i]
+			}
+			diffValue(fmt.Sprintf("%s[%d]", path, i), ov, nv, changes)
+		}
+		return
+	}
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	*changes = append(*changes, FieldChange{Path: path, Old: old, New: new})
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	if strings.ContainsAny(key, ".[]") {
+		// 如 metadata.labels 中的 app.kubernetes.io/name
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	return path + "." + key
+}
+
+// convertUnstructured 将对象转换为指定类型，T 为 *unstructured.Unstructured 时返回副本
+func convertUnstructured[T any](obj *unstructured.Unstructured) (T, error) {
+	if u, ok := any(obj.DeepCopy()).(T); ok {
+		return u, nil
+	}
+	var zero T
+	target := reflect.New(reflect.TypeOf(zero).Elem())
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, target.Interface()); err != nil {
+		return zero, err
+	}
+	return target.Interface().(T), nil
+}
@@ -3,7 +3,7 @@ package kom
 import (
 	"context"
 
-	"github.com/dgraph-io/ristretto/v2"
+	"github.com/weibaohui/kom/utils"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -80,7 +80,7 @@ func (k *Kubectl) Client() *kubernetes.Clientset {
 	cluster := Clusters().GetClusterById(k.ID)
 	return cluster.Client
 }
-func (k *Kubectl) ClusterCache() *ristretto.Cache[string, any] {
+func (k *Kubectl) ClusterCache() utils.Cache {
 	cache := Clusters().GetClusterById(k.ID).Cache
 	return cache
 }
@@ -0,0 +1,345 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/slice"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// ManagedWatchEvent 托管 watch 的事件，Object 已转换为调用方指定的类型
+type ManagedWatchEvent[T any] struct {
+	Type   watch.EventType // Added、Modified、Deleted
+	Object T
+	Relist bool // 是否由重新查询产生，重新查询时对比前后结果补发事件
+}
+
+// ManagedWatcher 自动重连的类型化 watch
+// 基于 Kubectl.Watch 实现：先查询当前对象并发出 Added 事件，之后从查询结果的 resourceVersion 开始 watch；
+// watch 断开后按退避时间重连，并从最后收到的 resourceVersion 继续；resourceVersion 过期（410 Gone）时重新查询，
+// 与之前的结果对比后补发 Added、Modified、Deleted 事件。
+//
+//	w := kom.NewManagedWatcher[*corev1.Pod](kom.DefaultCluster().Resource(&corev1.Pod{}).Namespace("default"))
+//	if err := w.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer w.Stop()
+//	for event := range w.ResultChan() {
+//		fmt.Println(event.Type, event.Object.Name)
+//	}
+type ManagedWatcher[T any] struct {
+	k       *Kubectl
+	opts    metav1.ListOptions
+	Backoff wait.Backoff // 重连的退避策略，Start 之前可修改
+
+	result          chan ManagedWatchEvent[T]
+	cancel          context.CancelFunc
+	done            chan struct{}
+	stopOnce        sync.Once
+	mu              sync.Mutex
+	resourceVersion string
+	known           map[string]*unstructured.Unstructured // 已发出事件的对象，重新查询时用于补发删除事件
+}
+
+// NewManagedWatcher 创建托管 watch，k 需指定资源及命名空间，可以使用 WithLabelSelector、WithFieldSelector 过滤
+// T 为事件中对象的类型，如 *corev1.Pod，使用 *unstructured.Unstructured 时不做转换
+func NewManagedWatcher[T any](k *Kubectl) *ManagedWatcher[T] {
+	stmt := *k.Statement
+	tx := &Kubectl{ID: k.ID, Error: k.Error, Statement: &stmt}
+	opts := metav1.ListOptions{}
+	if len(stmt.ListOptions) > 0 {
+		opts = stmt.ListOptions[0]
+	}
+	return &ManagedWatcher[T]{
+		k:    tx,
+		opts: opts,
+		Backoff: wait.Backoff{
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+			Steps:    10,
+			Cap:      30 * time.Second,
+		},
+		result: make(chan ManagedWatchEvent[T], 100),
+		done:   make(chan struct{}),
+		known:  map[string]*unstructured.Unstructured{},
+	}
+}
+
+// Start 启动 watch，首次查询失败时返回错误
+// ListOptions 中指定了 resourceVersion 时，不做首次查询，直接从该版本开始 watch
+func (w *ManagedWatcher[T]) Start(ctx context.Context) error {
+	if w.k.Error != nil {
+		return w.k.Error
+	}
+	if w.cancel != nil {
+		return fmt.Errorf("managed watcher already started")
+	}
+	if w.k.Statement.GVR.Empty() {
+		return fmt.Errorf("请先调用Resource()、CRD()、GVR()等方法指明操作对象的GVR")
+	}
+	var zero T
+	if reflect.TypeOf(zero) == nil || reflect.TypeOf(zero).Kind() != reflect.Ptr {
+		return fmt.Errorf("managed watcher 的对象类型必须为指针，如 *corev1.Pod")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
+	w.k.Statement.Context = ctx
+
+	var pending []ManagedWatchEvent[T]
+	if w.opts.ResourceVersion == "" {
+		events, err := w.relist(ctx, false)
+		if err != nil {
+			cancel()
+			close(w.done)
+			close(w.result)
+			return err
+		}
+		pending = events
+	} else {
+		w.setResourceVersion(w.opts.ResourceVersion)
+	}
+	go w.run(ctx, pending)
+	return nil
+}
+
+// ResultChan 事件通道，Stop 或 Context 取消后关闭
+func (w *ManagedWatcher[T]) ResultChan() <-chan ManagedWatchEvent[T] {
+	return w.result
+}
+
+// Stop 停止 watch，并等待后台协程退出
+func (w *ManagedWatcher[T]) Stop() {
+	if w.cancel == nil {
+		// 未启动
+		return
+	}
+	w.stopOnce.Do(w.cancel)
+	<-w.done
+}
+
+// ResourceVersion 最后收到的 resourceVersion
+func (w *ManagedWatcher[T]) ResourceVersion() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.resourceVersion
+}
+
+func (w *ManagedWatcher[T]) setResourceVersion(rv string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resourceVersion = rv
+}
+
+// run 持续 watch，断开后按退避时间重连
+func (w *ManagedWatcher[T]) run(ctx context.Context, pending []ManagedWatchEvent[T]) {
+	defer close(w.done)
+	defer close(w.result)
+	for _, event := range pending {
+		if !w.send(ctx, event) {
+			return
+		}
+	}
+
+	backoff := w.Backoff
+	for ctx.Err() == nil {
+		received, err := w.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			// 收到过事件，说明连接正常，重置退避时间
+			backoff = w.Backoff
+		}
+		if err != nil {
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				klog.V(6).Infof("managed watch %s resourceVersion %s expired, relist", w.k.Statement.GVR.Resource, w.ResourceVersion())
+				events, err := w.relist(ctx, true)
+				if err == nil {
+					ok := true
+					for _, event := range events {
+						if ok = w.send(ctx, event); !ok {
+							return
+						}
+					}
+					continue
+				}
+				klog.V(6).Infof("managed watch %s relist error %v", w.k.Statement.GVR.Resource, err)
+			} else {
+				klog.V(6).Infof("managed watch %s error %v", w.k.Statement.GVR.Resource, err)
+			}
+		}
+		delay := backoff.Step()
+		if backoff.Steps == 0 {
+			// 达到最大次数后保持最大间隔重试
+			backoff.Steps = 1
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watchOnce 建立一次 watch 并处理事件，直到断开
+// 返回是否收到过事件，以及断开的原因，正常断开时错误为 nil
+func (w *ManagedWatcher[T]) watchOnce(ctx context.Context) (bool, error) {
+	opts := w.opts
+	opts.ResourceVersion = w.ResourceVersion()
+	opts.AllowWatchBookmarks = true
+
+	// 每次 watch 使用语句的副本，clone 会丢失 NamespaceList 等条件
+	stmt := *w.k.Statement
+	tx := &Kubectl{ID: w.k.ID, Statement: &stmt}
+	var watcher watch.Interface
+	if err := tx.Watch(&watcher, opts).Error; err != nil {
+		return false, err
+	}
+	defer watcher.Stop()
+
+	received := false
+	for {
+		select {
+		case <-ctx.Done():
+			return received, ctx.Err()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				klog.V(6).Infof("managed watch %s closed, rewatch from %s", w.k.Statement.GVR.Resource, w.ResourceVersion())
+				return received, nil
+			}
+			received = true
+			switch event.Type {
+			case watch.Error:
+				return received, apierrors.FromObject(event.Object)
+			case watch.Bookmark:
+				if obj, ok := event.Object.(*unstructured.Unstructured); ok {
+					w.setResourceVersion(obj.GetResourceVersion())
+				}
+			case watch.Added, watch.Modified, watch.Deleted:
+				obj, ok := event.Object.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				w.setResourceVersion(obj.GetResourceVersion())
+				if !w.inNamespaces(obj) {
+					continue
+				}
+				key := managedWatchKey(obj)
+				if event.Type == watch.Deleted {
+					delete(w.known, key)
+				} else {
+					w.known[key] = obj
+				}
+				if e, ok := w.convert(event.Type, obj, false); ok && !w.send(ctx, e) {
+					return received, ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+// relist 重新查询全部对象，与已知对象对比后生成事件
+func (w *ManagedWatcher[T]) relist(ctx context.Context, relist bool) ([]ManagedWatchEvent[T], error) {
+	stmt := w.k.Statement
+	resource := stmt.Kubectl.DynamicClient().Resource(stmt.GVR)
+	opts := w.opts
+	opts.ResourceVersion = ""
+	var list *unstructured.UnstructuredList
+	var err error
+	if stmt.Namespaced {
+		list, err = resource.Namespace(w.namespace()).List(ctx, opts)
+	} else {
+		list, err = resource.List(ctx, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.setResourceVersion(list.GetResourceVersion())
+
+	var events []ManagedWatchEvent[T]
+	seen := map[string]bool{}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		if !w.inNamespaces(obj) {
+			continue
+		}
+		key := managedWatchKey(obj)
+		seen[key] = true
+		eventType := watch.Added
+		if old, ok := w.known[key]; ok {
+			if old.GetResourceVersion() == obj.GetResourceVersion() {
+				continue
+			}
+			eventType = watch.Modified
+		}
+		w.known[key] = obj
+		if e, ok := w.convert(eventType, obj, relist); ok {
+			events = append(events, e)
+		}
+	}
+	for key, old := range w.known {
+		if seen[key] {
+			continue
+		}
+		delete(w.known, key)
+		if e, ok := w.convert(watch.Deleted, old, relist); ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// namespace watch 的命名空间，与 Watch 回调的规则一致
+func (w *ManagedWatcher[T]) namespace() string {
+	stmt := w.k.Statement
+	if stmt.AllNamespace || len(stmt.NamespaceList) > 1 {
+		return metav1.NamespaceAll
+	}
+	if stmt.Namespace == "" {
+		return metav1.NamespaceDefault
+	}
+	return stmt.Namespace
+}
+
+// inNamespaces 传入多个命名空间时，watch 全部命名空间后过滤
+func (w *ManagedWatcher[T]) inNamespaces(obj *unstructured.Unstructured) bool {
+	stmt := w.k.Statement
+	if !stmt.Namespaced || stmt.AllNamespace || len(stmt.NamespaceList) <= 1 {
+		return true
+	}
+	return slice.Contain(stmt.NamespaceList, obj.GetNamespace())
+}
+
+// convert 将对象转换为调用方指定的类型
+func (w *ManagedWatcher[T]) convert(eventType watch.EventType, obj *unstructured.Unstructured, relist bool) (ManagedWatchEvent[T], bool) {
+	o, err := convertUnstructured[T](obj)
+	if err != nil {
+		klog.V(6).Infof("managed watch convert %s/%s error %v", obj.GetNamespace(), obj.GetName(), err)
+		return ManagedWatchEvent[T]{}, false
+	}
+	return ManagedWatchEvent[T]{Type: eventType, Object: o, Relist: relist}, true
+}
+
+// send 发送事件，Context 取消时返回 false
+func (w *ManagedWatcher[T]) send(ctx context.Context, event ManagedWatchEvent[T]) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case w.result <- event:
+		return true
+	}
+}
+
+func managedWatchKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+}
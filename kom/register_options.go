@@ -6,6 +6,8 @@ import (
     "time"
 
     "github.com/dgraph-io/ristretto/v2"
+    "github.com/weibaohui/kom/utils"
+    "k8s.io/apimachinery/pkg/runtime/schema"
     "k8s.io/client-go/rest"
 )
 
@@ -26,6 +28,8 @@ type RegisterParams struct {
     // cluster initialization options
     DisableCRDWatch bool
     CacheConfig     *ristretto.Config[string, any]
+    Cache           utils.Cache
+    InformerGVRs    []schema.GroupVersionResource
 }
 
 // RegisterOption is the registration-time only option.
@@ -90,4 +94,14 @@ func RegisterDisableCRDWatch() RegisterOption {
 // RegisterCacheConfig sets custom cache configuration for the cluster.
 func RegisterCacheConfig(cfg *ristretto.Config[string, any]) RegisterOption {
     return func(p *RegisterParams) { p.CacheConfig = cfg }
-}
\ No newline at end of file
+}
+
+// RegisterInformerCache starts shared informers for the given resources and serves List/Get from their indexers.
+func RegisterInformerCache(gvrs ...schema.GroupVersionResource) RegisterOption {
+    return func(p *RegisterParams) { p.InformerGVRs = append(p.InformerGVRs, gvrs...) }
+}
+
+// RegisterCache sets a custom cache backend for the cluster, such as utils.NewRedisCache. CacheConfig is ignored.
+func RegisterCache(cache utils.Cache) RegisterOption {
+    return func(p *RegisterParams) { p.Cache = cache }
+}
@@ -60,13 +60,15 @@ func (k *Kubectl) Namespace(namespaces ...string) *Kubectl {
 	}
 
 	var parts []string
+	var values []interface{}
 	for _, ns := range tx.Statement.NamespaceList {
-		parts = append(parts, fmt.Sprintf("metadata.namespace='%s'", ns))
+		parts = append(parts, "metadata.namespace=?")
+		values = append(values, ns)
 	}
 	result := strings.Join(parts, " or ")
 	result = fmt.Sprintf("(%s)", result)
 	if result != "()" {
-		k.Where(result)
+		k.Where(result, values...)
 	}
 	return tx
 }
@@ -138,6 +140,9 @@ func (k *Kubectl) GetLogs(requestPtr interface{}, opt *v1.PodLogOptions) *Kubect
 	tx.Statement.PodLogOptions = opt
 	tx.Statement.PodLogOptions.Container = tx.Statement.ContainerName
 	tx.Statement.Dest = requestPtr
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Logs().Execute(tx)
 	return tx
 }
@@ -145,6 +150,9 @@ func (k *Kubectl) GetLogs(requestPtr interface{}, opt *v1.PodLogOptions) *Kubect
 func (k *Kubectl) Get(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Get().Execute(tx)
 	return tx
 }
@@ -158,12 +166,18 @@ func (k *Kubectl) DocField(field string) *Kubectl {
 func (k *Kubectl) Doc(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Doc().Execute(tx)
 	return tx
 }
 func (k *Kubectl) Describe(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Describe().Execute(tx)
 	return tx
 }
@@ -191,6 +205,9 @@ func (k *Kubectl) List(dest interface{}, opt ...metav1.ListOptions) *Kubectl {
 	}
 
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().List().Execute(tx)
 	return tx
 }
@@ -208,6 +225,9 @@ func mergeSelectors(selector1, selector2 string) string {
 func (k *Kubectl) Create(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Create().Execute(tx)
 	return tx
 }
@@ -215,23 +235,35 @@ func (k *Kubectl) Watch(dest interface{}, opt ...metav1.ListOptions) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.ListOptions = opt
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Watch().Execute(tx)
 	return tx
 }
 func (k *Kubectl) Update(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Update().Execute(tx)
 	return tx
 }
 func (k *Kubectl) Delete() *Kubectl {
 	tx := k.getInstance()
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Delete().Execute(tx)
 	return tx
 }
 func (k *Kubectl) ForceDelete() *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.ForceDelete = true
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Delete().Execute(tx)
 	return tx
 }
@@ -240,6 +272,9 @@ func (k *Kubectl) Patch(dest interface{}, pt types.PatchType, data string) *Kube
 	tx.Statement.Dest = dest
 	tx.Statement.PatchData = data
 	tx.Statement.PatchType = pt
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Patch().Execute(tx)
 	return tx
 }
@@ -249,6 +284,9 @@ func (k *Kubectl) Patch(dest interface{}, pt types.PatchType, data string) *Kube
 func (k *Kubectl) Execute(dest interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Dest = dest
+	if tx.Error != nil {
+		return tx
+	}
 	tx.Error = tx.Callback().Exec().Execute(tx)
 	return tx
 }
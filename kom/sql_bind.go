@@ -0,0 +1,212 @@
+package kom
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	"github.com/xwb1989/sqlparser"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// sqlArgs sql 参数绑定
+// 支持位置参数 ? 及命名参数 :name，命名参数使用 map[string]interface{} 传入。
+// 参数在解析之后按语法树绑定，参数值不会作为 sql 再次解析。
+//
+//	Sql("select * from pod where metadata.name = ? and metadata.namespace = :ns", "abc", map[string]interface{}{"ns": "default"})
+type sqlArgs struct {
+	positional []interface{}
+	named      map[string]interface{}
+}
+
+// sqlparser 将 ? 转换为 :v1、:v2 ...
+var positionalArgPattern = regexp.MustCompile(`^v\d+$`)
+
+// newSqlArgs 区分位置参数及命名参数
+func newSqlArgs(values []interface{}) *sqlArgs {
+	args := &sqlArgs{named: map[string]interface{}{}}
+	for _, value := range values {
+		if named, ok := value.(map[string]interface{}); ok {
+			for k, v := range named {
+				args.named[k] = v
+			}
+			continue
+		}
+		args.positional = append(args.positional, value)
+	}
+	return args
+}
+
+// check 检查语句中的参数与传入的参数是否一致
+func (a *sqlArgs) check(stmt sqlparser.SQLNode) error {
+	used := map[string]bool{}
+	positional := 0
+	for _, name := range argNames(stmt) {
+		if positionalArgPattern.MatchString(name) {
+			if _, ok := a.named[name]; !ok {
+				positional++
+				continue
+			}
+		}
+		if _, ok := a.named[name]; !ok {
+			return fmt.Errorf("missing value for parameter :%s", name)
+		}
+		used[name] = true
+	}
+	if positional != len(a.positional) {
+		return fmt.Errorf("sql has %d positional parameters but %d values were given", positional, len(a.positional))
+	}
+	for name := range a.named {
+		if !used[name] {
+			return fmt.Errorf("named parameter :%s is not used in sql", name)
+		}
+	}
+	return nil
+}
+
+// argNames 获取语句中的参数名称，如 v1、name
+func argNames(node sqlparser.SQLNode) []string {
+	var names []string
+	_ = sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		if v, ok := n.(*sqlparser.SQLVal); ok && v.Type == sqlparser.ValArg {
+			names = append(names, strings.TrimPrefix(string(v.Val), ":"))
+		}
+		return true, nil
+	}, node)
+	return names
+}
+
+// lookup 获取参数值
+func (a *sqlArgs) lookup(arg *sqlparser.SQLVal) (interface{}, error) {
+	name := strings.TrimPrefix(string(arg.Val), ":")
+	if a != nil {
+		if v, ok := a.named[name]; ok {
+			return v, nil
+		}
+		if positionalArgPattern.MatchString(name) {
+			var index int
+			_, _ = fmt.Sscanf(name, "v%d", &index)
+			if index >= 1 && index <= len(a.positional) {
+				return a.positional[index-1], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("missing value for parameter :%s", name)
+}
+
+// subset 获取子查询中使用到的参数，均按命名参数传递
+func (a *sqlArgs) subset(node sqlparser.SQLNode) map[string]interface{} {
+	var result map[string]interface{}
+	for _, name := range argNames(node) {
+		v, err := a.lookup(&sqlparser.SQLVal{Type: sqlparser.ValArg, Val: []byte(":" + name)})
+		if err != nil {
+			continue
+		}
+		if result == nil {
+			result = map[string]interface{}{}
+		}
+		result[name] = v
+	}
+	return result
+}
+
+// literal 获取条件中的值，参数按绑定的值返回，列表返回 []string，其余返回去掉引号的字符串
+func (a *sqlArgs) literal(expr sqlparser.Expr) (interface{}, error) {
+	switch v := expr.(type) {
+	case *sqlparser.SQLVal:
+		if v.Type == sqlparser.ValArg {
+			value, err := a.lookup(v)
+			if err != nil {
+				return nil, err
+			}
+			return boundValue(value), nil
+		}
+	case sqlparser.ValTuple:
+		if len(argNames(v)) == 0 {
+			break
+		}
+		// in (?, ?)、in (?) 传入切片
+		values := make([]string, 0)
+		for _, e := range v {
+			value, err := a.literal(e)
+			if err != nil {
+				return nil, err
+			}
+			switch item := value.(type) {
+			case []string:
+				values = append(values, item...)
+			default:
+				values = append(values, fmt.Sprintf("%v", item))
+			}
+		}
+		return values, nil
+	}
+	return utils.TrimQuotes(sqlparser.String(expr)), nil
+}
+
+// typed 获取参数值及其类型，用于比较条件的右侧
+// 字符串保持为字符串，数字转换为 float64，时间、Duration、Quantity、布尔值保持原类型，切片转换为 []string，
+// 其余类型按文本探测类型。ordered 为 true 时用于 >、<、>=、<=、between，字符串按文本探测类型，
+// 使 "2024-01-01T12:00:00Z"、"400m" 按时间、Quantity 比较
+func (a *sqlArgs) typed(arg *sqlparser.SQLVal, ordered bool) (string, interface{}, error) {
+	value, err := a.lookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	switch v := value.(type) {
+	case nil:
+		return utils.TypeString, "", nil
+	case string:
+		if ordered {
+			valueType, value := utils.DetectType(v)
+			return valueType, value, nil
+		}
+		return utils.TypeString, v, nil
+	case bool:
+		return utils.TypeBoolean, v, nil
+	case time.Time:
+		return utils.TypeTime, v, nil
+	case time.Duration:
+		return utils.TypeDuration, v, nil
+	case resource.Quantity:
+		return utils.TypeQuantity, v, nil
+	case *resource.Quantity:
+		return utils.TypeQuantity, *v, nil
+	case []string, []interface{}:
+		return "", boundValue(v), nil
+	}
+	switch rv := reflect.ValueOf(value); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return utils.TypeNumber, float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return utils.TypeNumber, float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return utils.TypeNumber, rv.Float(), nil
+	}
+	valueType, v := utils.DetectType(boundValue(value))
+	return valueType, v, nil
+}
+
+// boundValue 将参数值转换为条件值，切片转换为 []string，其余转换为字符串，再由 DetectType 探测类型
+func boundValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprintf("%v", boundValue(item)))
+		}
+		return values
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -2,7 +2,6 @@ package kom
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/weibaohui/kom/utils"
@@ -10,21 +9,27 @@ import (
 	"k8s.io/klog/v2"
 )
 
-// Sql TODO Insert Update Delete
+// Sql 解析sql为函数调用，实现支持原生sql语句
 //
-//	 已支持Select
-//		解析sql为函数调用，实现支持原生sql语句
+//	select 语句配合 List 使用
+//	update、delete 语句配合 Exec 使用，必须包含 where 条件
 //
-// select * from pod where pod.name='?', 'abc'
+// 参数使用占位符绑定，支持位置参数 ? 及命名参数 :name，命名参数使用 map[string]interface{} 传入
+//
+// select * from pod where metadata.name=?, 'abc'
+// select * from pod where metadata.namespace=:ns, map[string]interface{}{"ns": "default"}
+// delete from pod where status.phase='Failed'
+// update deployment set spec.replicas=0 where metadata.labels.env='dev'
 func (k *Kubectl) Sql(sql string, values ...interface{}) *Kubectl {
 	tx := k.getInstance()
 	tx.AllNamespace()
 
-	sql = formatSql(sql, values)
-
-	// 添加反引号，将metadata.name 转为`metadata.name`,
+	// 添加反引号，将超过三级的字段路径转为`spec.containers.resources.limits.cpu`,
 	// k8s中很多类似json的字段，需要用反引号进行包裹，避免被作为db.table形式使用
-	// sql = NewSqlParse(sql).AddBackticks()
+	sql = normalizeSql(sql)
+
+	// 提取 order by 中的 nulls first、nulls last
+	sql, nulls := extractNullsOrder(sql)
 
 	stmt, err := sqlparser.Parse(sql)
 	if err != nil {
@@ -33,54 +38,132 @@ func (k *Kubectl) Sql(sql string, values ...interface{}) *Kubectl {
 		return tx
 	}
 
-	var conditions []*Condition // 存储解析后的条件
+	// 检查参数个数
+	args := newSqlArgs(values)
+	if err = args.check(stmt); err != nil {
+		tx.Error = err
+		return tx
+	}
 
-	// 断言为 *sqlparser.Select 类型
-	selectStmt, ok := stmt.(*sqlparser.Select)
-	if !ok {
-		log.Fatalf("Not a SELECT statement")
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		tx.Statement.Filter.Action = SqlActionSelect
+		err = tx.parseSelect(s, nulls, args)
+	case *sqlparser.Update:
+		tx.Statement.Filter.Action = SqlActionUpdate
+		err = tx.parseUpdate(s, nulls, args)
+	case *sqlparser.Delete:
+		tx.Statement.Filter.Action = SqlActionDelete
+		err = tx.parseDelete(s, nulls, args)
+	default:
+		err = fmt.Errorf("不支持的 SQL 语句，仅支持 select、update、delete: %s", sql)
 	}
-	// 获取 Select 语句中的 From 作为Resource
-	from := sqlparser.String(selectStmt.From)
-	gvk := k.Tools().FindGVKByTableNameInApiResources(from)
-	if gvk == nil {
-		tx.Error = fmt.Errorf("resource %s not found both in api-resource and crd", from)
-		klog.V(6).Infof("resource %s not found both in api-resource and crd", from)
-		names := k.Tools().ListAvailableTableNames()
-		klog.V(6).Infof("Available resource: %s", names)
+	if err != nil {
+		tx.Error = err
 		return tx
 	}
 
-	// 设置GVK
-	tx.GVK(gvk.Group, gvk.Version, gvk.Kind)
+	tx.Statement.Filter.Parsed = true
+	return tx
+}
+
+// parseSelect 解析 select 语句
+func (k *Kubectl) parseSelect(selectStmt *sqlparser.Select, nulls []string, args *sqlArgs) error {
+	// 获取 Select 语句中的 From 作为Resource
+	table, joins, err := parseFrom(selectStmt.From)
+	if err != nil {
+		return err
+	}
+	if table.cluster != "" {
+		// 跨集群查询，如 prod.pod、ALL_CLUSTERS.pod
+		if err = k.setClusterTable(table.cluster, table.name); err != nil {
+			return err
+		}
+	} else if err = k.setTable(table.name); err != nil {
+		return err
+	}
+
+	// 设置 join 的表，使用主表所在的集群解析
+	if len(joins) > 0 {
+		resolver := k
+		if table.cluster != "" && !strings.EqualFold(table.cluster, AllClustersSchema) {
+			resolver = Cluster(table.cluster)
+		}
+		for _, join := range joins {
+			joinGVK := resolver.Tools().FindGVKByTableNameInApiResources(join.Table)
+			if joinGVK == nil {
+				return fmt.Errorf("resource %s not found both in api-resource and crd", join.Table)
+			}
+			join.GVK = *joinGVK
+		}
+		k.Statement.Filter.TableAlias = table.aliasOrName()
+		k.Statement.Filter.Joins = joins
+	}
+
+	// 解析查询列
+	if err = k.setProjection(selectStmt.SelectExprs); err != nil {
+		return err
+	}
 
 	// 获取 LIMIT 子句信息
-	limit := selectStmt.Limit
-	if limit != nil {
-		// 获取 LIMIT 的 Rowcount 和 Offset
-		rowCount := sqlparser.String(limit.Rowcount)
-		offset := sqlparser.String(limit.Offset)
+	if err = k.setLimit(selectStmt.Limit, args); err != nil {
+		return err
+	}
 
-		tx.Limit(utils.ToInt(rowCount))
-		tx.Offset(utils.ToInt(offset))
+	// 解析Where语句，获得执行条件
+	if k.Statement.Filter.Expr, k.Statement.Filter.Conditions, err = parseWhere(selectStmt.Where, args); err != nil {
+		return err
 	}
-	// 解析Where语句，活的执行条件
-	conditions = parseWhereExpr(conditions, 0, "AND", selectStmt.Where.Expr)
 
-	// 探测 conditions中的条件值类型
-	for i, cond := range conditions {
-		conditions[i].ValueType, conditions[i].Value = utils.DetectType(cond.Value)
+	// 解析分组及分组过滤条件
+	k.Statement.Filter.GroupBy = parseGroupBy(selectStmt.GroupBy)
+	if k.Statement.Filter.Having, _, err = parseWhere(selectStmt.Having, args); err != nil {
+		return err
 	}
-	tx.Statement.Filter.Conditions = conditions
 
 	// 设置排序字段
-	orderBy := selectStmt.OrderBy
-	if orderBy != nil {
-		tx.Statement.Filter.Order = sqlparser.String(orderBy)
+	if selectStmt.OrderBy != nil {
+		k.Statement.Filter.Order = formatOrderBy(selectStmt.OrderBy, nulls)
 	}
+	return nil
+}
 
-	tx.Statement.Filter.Parsed = true
-	return tx
+// setTable 根据表名设置GVK
+func (k *Kubectl) setTable(from string) error {
+	gvk := k.Tools().FindGVKByTableNameInApiResources(from)
+	if gvk == nil {
+		klog.V(6).Infof("resource %s not found both in api-resource and crd", from)
+		names := k.Tools().ListAvailableTableNames()
+		klog.V(6).Infof("Available resource: %s", names)
+		return fmt.Errorf("resource %s not found both in api-resource and crd", from)
+	}
+
+	// 设置GVK
+	k.GVK(gvk.Group, gvk.Version, gvk.Kind)
+	k.Statement.Filter.From = from
+	return nil
+}
+
+// setLimit 设置 LIMIT 的 Rowcount 和 Offset，支持参数绑定
+func (k *Kubectl) setLimit(limit *sqlparser.Limit, args *sqlArgs) error {
+	if limit == nil {
+		return nil
+	}
+	if limit.Rowcount != nil {
+		rowCount, err := args.literal(limit.Rowcount)
+		if err != nil {
+			return err
+		}
+		k.Limit(utils.ToInt(fmt.Sprintf("%v", rowCount)))
+	}
+	if limit.Offset != nil {
+		offset, err := args.literal(limit.Offset)
+		if err != nil {
+			return err
+		}
+		k.Offset(utils.ToInt(fmt.Sprintf("%v", offset)))
+	}
+	return nil
 }
 
 func (k *Kubectl) From(tableName string) *Kubectl {
@@ -98,27 +181,61 @@ func (k *Kubectl) From(tableName string) *Kubectl {
 	tx.GVK(gvk.Group, gvk.Version, gvk.Kind)
 	return tx
 }
+
+// Select 设置查询列，支持别名及嵌套字段
+// Select("metadata.name", "status.phase as phase", "spec.containers.image")
+// 需配合 List(&[]kom.Row{}) 或 List(&[]map[string]interface{}{}) 使用，只返回查询列
+func (k *Kubectl) Select(columns ...string) *Kubectl {
+	tx := k.getInstance()
+	sql := normalizeSql(fmt.Sprintf("select %s from fake", strings.Join(columns, ",")))
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		klog.Errorf("Error parsing SQL:%s,%v", sql, err)
+		tx.Error = err
+		return tx
+	}
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		tx.Error = fmt.Errorf("not a select column list: %s", strings.Join(columns, ","))
+		return tx
+	}
+	if err = tx.setProjection(selectStmt.SelectExprs); err != nil {
+		tx.Error = err
+	}
+	return tx
+}
+
+// setProjection 解析并设置查询列
+func (k *Kubectl) setProjection(exprs sqlparser.SelectExprs) error {
+	columns, err := parseSelectExprs(exprs)
+	if err != nil {
+		return err
+	}
+	k.Statement.Filter.Projection = columns
+	k.Statement.Filter.Columns = nil
+	for _, c := range columns {
+		k.Statement.Filter.Columns = append(k.Statement.Filter.Columns, c.Field)
+	}
+	return nil
+}
+
+// Where 设置查询条件，多次调用时使用 and 连接
+// 参数使用占位符绑定，支持位置参数 ? 及命名参数 :name，参数值不会作为 sql 解析
+//
+//	Where("metadata.name = ? or metadata.labels.app = :app", "abc", map[string]interface{}{"app": "nginx"})
+//	Where("metadata.namespace in (?)", []string{"default", "kube-system"})
 func (k *Kubectl) Where(condition string, values ...interface{}) *Kubectl {
 	tx := k.getInstance()
-	originalSql := tx.Statement.Filter.Sql
-	sql := formatSql(condition, values)
 
-	trimSql := strings.ReplaceAll(sql, " ", "")
+	trimSql := strings.ReplaceAll(condition, " ", "")
 	if trimSql == "(())" || trimSql == "()" || trimSql == "" {
 		// 没有内容
 		return tx
 	}
-	if originalSql != "" {
-		sql = originalSql + " and ( " + sql + " ) "
-	} else {
-		sql = fmt.Sprintf(" select * from fake where ( %s )", sql)
-	}
 
-	// 添加反引号，将metadata.name 转为`metadata.name`,
+	// 添加反引号，将超过三级的字段路径转为`spec.containers.resources.limits.cpu`,
 	// k8s中很多类似json的字段，需要用反引号进行包裹，避免被作为db.table形式使用
-	// sql = NewSqlParse(sql).AddBackticks()
-
-	tx.Statement.Filter.Sql = sql
+	sql := normalizeSql(fmt.Sprintf(" select * from fake where ( %s )", condition))
 
 	stmt, err := sqlparser.Parse(sql)
 	if err != nil {
@@ -127,51 +244,89 @@ func (k *Kubectl) Where(condition string, values ...interface{}) *Kubectl {
 		return tx
 	}
 
-	var conditions []*Condition // 存储解析后的条件
-
 	// 断言为 *sqlparser.Select 类型
 	selectStmt, ok := stmt.(*sqlparser.Select)
 	if !ok {
-		klog.Errorf("not select parsing SQL:%s,%v", sql, err)
-		tx.Error = err
+		tx.Error = fmt.Errorf("not a where condition: %s", condition)
 		return tx
 	}
 
-	// 解析Where语句，获得执行条件
-	conditions = parseWhereExpr(conditions, 0, "AND", selectStmt.Where.Expr)
+	args := newSqlArgs(values)
+	if tx.Error = args.check(selectStmt.Where); tx.Error != nil {
+		return tx
+	}
 
-	// 探测 conditions中的条件值类型
-	for i, cond := range conditions {
-		conditions[i].ValueType, conditions[i].Value = utils.DetectType(cond.Value)
+	// 解析Where语句，获得执行条件，与之前的条件使用 and 连接
+	expr, conditions, err := parseWhere(selectStmt.Where, args)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	filter := &tx.Statement.Filter
+	filter.Expr = newLogicNode(WhereOpAnd, filter.WhereTree(), expr)
+	filter.Conditions = append(filter.Conditions, conditions...)
+	if filter.Sql != "" {
+		filter.Sql = filter.Sql + " and ( " + condition + " ) "
+	} else {
+		filter.Sql = sql
 	}
+	filter.Parsed = true
 
-	tx.Statement.Filter.Conditions = conditions
+	return tx
+}
 
-	tx.Statement.Filter.Parsed = true
+// GroupBy 设置分组字段
+// GroupBy("spec.nodeName", "status.phase")
+// 需配合 Select 中的聚合函数使用，结果使用 List(&[]kom.Row{}) 承载
+func (k *Kubectl) GroupBy(fields ...string) *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.Filter.GroupBy = fields
+	return tx
+}
 
+// Having 设置分组过滤条件，作用于分组聚合后的结果行
+// Having("count(*) > ?", 2)
+func (k *Kubectl) Having(condition string, values ...interface{}) *Kubectl {
+	tx := k.getInstance()
+	sql := normalizeSql(fmt.Sprintf("select * from fake group by fake having %s", condition))
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		klog.Errorf("Error parsing SQL:%s,%v", sql, err)
+		tx.Error = err
+		return tx
+	}
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		tx.Error = fmt.Errorf("not a having condition: %s", condition)
+		return tx
+	}
+	having := selectStmt.Having
+	args := newSqlArgs(values)
+	if tx.Error = args.check(having); tx.Error != nil {
+		return tx
+	}
+	tx.Statement.Filter.Having, _, tx.Error = parseWhere(having, args)
 	return tx
 }
 
-// formatSql
-//
-//	select * from pod where pod.name='?', 'abc'
-func formatSql(condition string, values []interface{}) string {
-	// 将 values 替换到 condition 中的占位符 ?
-	for _, value := range values {
-		// 对值进行安全格式化，例如字符串加单引号
-		switch v := value.(type) {
-		case string:
-			condition = strings.Replace(condition, "?", fmt.Sprintf("'%s'", v), 1)
-		default:
-			condition = strings.Replace(condition, "?", fmt.Sprintf("%v", v), 1)
+// formatOrderBy 将 order by 子句转换为排序字符串，如 metadata.name asc, count(*) desc nulls last
+func formatOrderBy(orderBy sqlparser.OrderBy, nulls []string) string {
+	var parts []string
+	for i, o := range orderBy {
+		part := fmt.Sprintf("%s %s", fieldPath(o.Expr), o.Direction)
+		if i < len(nulls) && nulls[i] != "" {
+			part += " " + nulls[i]
 		}
+		parts = append(parts, part)
 	}
-	return condition
+	return strings.Join(parts, ", ")
 }
 
 // Order
 // Order(" id desc")
 // Order(" date asc")
+// Order("status.phase asc, metadata.creationTimestamp desc nulls last")
+// 支持多个字段排序，未指定 nulls first/last 时，asc 排序字段不存在的排在最后，desc 排在最前
 func (k *Kubectl) Order(order string) *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.Filter.Order = order
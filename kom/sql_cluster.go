@@ -0,0 +1,97 @@
+package kom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+// AllClustersSchema 查询所有集群的伪 schema，如 select * from ALL_CLUSTERS.pod
+const AllClustersSchema = "ALL_CLUSTERS"
+
+// ClusterColumn 跨集群查询结果中的集群列，可用于 select、where、group by、order by
+const ClusterColumn = "cluster"
+
+// IsMultiCluster 是否为跨集群查询
+func (f *Filter) IsMultiCluster() bool {
+	return f.AllClusters || len(f.Clusters) > 0
+}
+
+// setClusterTable 设置跨集群查询的集群及表名
+// 表名使用指定的集群解析，查询所有集群时使用当前集群解析，执行时再按各集群分别解析
+func (k *Kubectl) setClusterTable(cluster string, from string) error {
+	resolver := k
+	if strings.EqualFold(cluster, AllClustersSchema) {
+		k.Statement.Filter.AllClusters = true
+	} else {
+		resolver = Cluster(cluster)
+		if resolver == nil {
+			return fmt.Errorf("cluster %s not found", cluster)
+		}
+		k.Statement.Filter.Clusters = []string{cluster}
+	}
+
+	gvk := resolver.Tools().FindGVKByTableNameInApiResources(from)
+	if gvk == nil {
+		return fmt.Errorf("resource %s not found both in api-resource and crd of cluster %s", from, cluster)
+	}
+	k.Statement.useCustomGVK = true
+	k.Statement.GVK = *gvk
+	k.Statement.GVR, k.Statement.Namespaced, _ = resolver.Tools().GetGVRByGVK(*gvk)
+	k.Statement.Filter.From = from
+	return nil
+}
+
+// targetClusters 跨集群查询的目标集群ID，按ID排序
+func (s *Statement) targetClusters() []string {
+	if !s.Filter.AllClusters {
+		return s.Filter.Clusters
+	}
+	var ids []string
+	for id := range Clusters().AllClusters() {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// clusterPredicate 判断是否为集群条件
+// 支持 cluster='a'、cluster in ('a','b')，以及多个等值条件的 or 组合
+func (s *Statement) clusterPredicate(node *WhereNode) ([]string, bool) {
+	switch node.Op {
+	case WhereOpCondition:
+		c := node.Condition
+		if c.Field != ClusterColumn || c.Quantifier != "" {
+			return nil, false
+		}
+		switch c.Operator {
+		case "=":
+			if v, ok := c.Value.(string); ok {
+				return []string{v}, true
+			}
+		case "in":
+			return listValues(c.Value)
+		}
+	case WhereOpOr:
+		var clusters []string
+		for _, child := range node.Children {
+			ids, ok := s.clusterPredicate(child)
+			if !ok {
+				return nil, false
+			}
+			clusters = append(clusters, ids...)
+		}
+		return slice.Unique(clusters), true
+	}
+	return nil, false
+}
+
+// FillClusterErrors 跨集群查询时，返回各集群的错误
+// 部分集群失败不影响整体查询，只有全部集群都失败时才返回错误
+func (k *Kubectl) FillClusterErrors(errs *map[string]error) *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.ClusterErrors = errs
+	return tx
+}
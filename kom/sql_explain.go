@@ -0,0 +1,270 @@
+package kom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weibaohui/kom/kom/doc"
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SqlExplain sql 执行计划
+type SqlExplain struct {
+	Sql    string                  `json:"sql"`
+	GVK    schema.GroupVersionKind `json:"GVK"`
+	Fields []*FieldExplain         `json:"fields"` // select、where、group by、order by 中的字段
+	Plan   *PushdownPlan           `json:"plan"`   // 谓词下推计划
+}
+
+// FieldExplain 字段路径的校验结果
+type FieldExplain struct {
+	Clause string `json:"clause"` // 所在子句，select、where、group by、order by，子查询中的字段以 subquery 开头
+	*doc.FieldPath
+	ValueType string `json:"valueType,omitempty"` // where 条件值的类型
+	Warning   string `json:"warning,omitempty"`   // 字段类型与条件值类型不一致等提示
+}
+
+// Explain 解析 sql 并输出执行计划，不执行查询
+// 按照集群的 OpenAPI Schema 校验 select、where、group by、order by 中的字段路径，
+// 对不存在的字段给出相近字段的提示，推断字段类型并检查与条件值类型是否一致，
+// 同时给出哪些条件由 API Server 处理，哪些在内存中计算。
+//
+//	explain, err := kom.DefaultCluster().Explain("select * from pod where metadata.nmae='abc'")
+//	fmt.Println(explain)
+func (k *Kubectl) Explain(sql string, values ...interface{}) (*SqlExplain, error) {
+	tx := k.Sql(sql, values...)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	stmt := tx.Statement
+	filter := &stmt.Filter
+	e := &SqlExplain{
+		Sql:  sql,
+		GVK:  stmt.GVK,
+		Plan: tx.PushdownPlan(),
+	}
+
+	for _, c := range filter.Projection {
+		if c.Field != "*" {
+			e.addField(tx, "select", c.Field)
+		}
+	}
+	for _, c := range collectConditions(filter.WhereTree()) {
+		if err := e.addCondition(tx, c); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range filter.GroupBy {
+		e.addField(tx, "group by", f)
+	}
+	for _, f := range orderFields(filter.Order) {
+		if isResultColumn(filter, f) {
+			// 按查询列的别名或聚合列排序
+			continue
+		}
+		e.addField(tx, "order by", f)
+	}
+	return e, nil
+}
+
+// addCondition 校验 where 条件中的字段，并检查条件值的类型
+func (e *SqlExplain) addCondition(k *Kubectl, c *Condition) error {
+	if c.Func != nil {
+		for _, f := range funcFields(c.Func) {
+			e.addField(k, "where", f)
+		}
+		return nil
+	}
+	field := e.addField(k, "where", c.Field)
+	if c.Subquery != "" {
+		var args []interface{}
+		if len(c.SubqueryArgs) > 0 {
+			args = append(args, c.SubqueryArgs)
+		}
+		sub, err := k.newInstance().Explain(c.Subquery, args...)
+		if err != nil {
+			return err
+		}
+		for _, f := range sub.Fields {
+			f.Clause = "subquery " + f.Clause
+			e.Fields = append(e.Fields, f)
+		}
+		return nil
+	}
+	if field.Valid && c.ValueType != "" {
+		field.ValueType = c.ValueType
+		if !compatibleValueType(field.FieldPath, c) {
+			field.Warning = fmt.Sprintf("%s value %v does not match field type %s", c.ValueType, c.Value, fieldTypeName(field.FieldPath))
+		}
+	}
+	return nil
+}
+
+// addField 按照字段所属资源的 OpenAPI Schema 校验字段路径
+func (e *SqlExplain) addField(k *Kubectl, clause string, field string) *FieldExplain {
+	if field == ClusterColumn && k.Statement.Filter.IsMultiCluster() {
+		// 跨集群查询的集群ID列
+		result := &FieldExplain{Clause: clause, FieldPath: &doc.FieldPath{Path: field, Valid: true, Type: "string"}}
+		e.Fields = append(e.Fields, result)
+		return result
+	}
+	gvk, path := fieldResource(k, field)
+	apiDoc := doc.DocField{
+		Kind:          gvk.Kind,
+		ApiVersion:    gvk.GroupVersion(),
+		OpenapiSchema: k.Status().OpenAPISchema(),
+	}
+	result := &FieldExplain{Clause: clause, FieldPath: apiDoc.ResolvePath(path)}
+	result.Path = field
+	e.Fields = append(e.Fields, result)
+	return result
+}
+
+// Errors 不存在的字段
+func (e *SqlExplain) Errors() []error {
+	var errs []error
+	for _, f := range e.Fields {
+		if f.Valid {
+			continue
+		}
+		msg := fmt.Sprintf("%s: unknown field %s", f.Clause, f.Unknown)
+		if len(f.Suggestions) > 0 {
+			msg = fmt.Sprintf("%s, did you mean %s", msg, strings.Join(f.Suggestions, " or "))
+		}
+		errs = append(errs, fmt.Errorf("%s", msg))
+	}
+	return errs
+}
+
+// String 输出执行计划
+func (e *SqlExplain) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("sql: %s\n", e.Sql))
+	sb.WriteString(fmt.Sprintf("resource: %s %s\n", e.GVK.GroupVersion().String(), e.GVK.Kind))
+	sb.WriteString("fields:\n")
+	for _, f := range e.Fields {
+		if !f.Valid {
+			sb.WriteString(fmt.Sprintf("  [%s] %s: unknown field %s", f.Clause, f.Path, f.Unknown))
+			if len(f.Suggestions) > 0 {
+				sb.WriteString(fmt.Sprintf(", did you mean %s?", strings.Join(f.Suggestions, ", ")))
+			}
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  [%s] %s: %s", f.Clause, f.Path, fieldTypeName(f.FieldPath)))
+		if f.ValueType != "" {
+			sb.WriteString(fmt.Sprintf(", value %s", f.ValueType))
+		}
+		if f.Warning != "" {
+			sb.WriteString(fmt.Sprintf(", warning: %s", f.Warning))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(e.Plan.Explain())
+	return sb.String()
+}
+
+// fieldTypeName 字段类型的描述，如 integer(int32)、[]string
+func fieldTypeName(f *doc.FieldPath) string {
+	name := f.Type
+	if name == "" {
+		name = "unknown"
+	}
+	if f.Format != "" {
+		name = fmt.Sprintf("%s(%s)", name, f.Format)
+	}
+	if f.Array {
+		name = "[]" + name
+	}
+	return name
+}
+
+// compatibleValueType 判断条件值的类型与字段类型是否一致，只检查比较操作符
+func compatibleValueType(f *doc.FieldPath, c *Condition) bool {
+	switch c.Operator {
+	case "=", "!=", "<", ">", "<=", ">=":
+	default:
+		return true
+	}
+	switch {
+	case f.Format == "quantity":
+		return c.ValueType == utils.TypeNumber || c.ValueType == utils.TypeQuantity
+	case f.Format == "date-time":
+		return c.ValueType == utils.TypeTime
+	case f.Format == "int-or-string":
+		return true
+	}
+	switch f.Type {
+	case "integer", "number":
+		return c.ValueType == utils.TypeNumber
+	case "boolean":
+		return c.ValueType == utils.TypeBoolean
+	}
+	return true
+}
+
+// fieldResource 获取字段所属的资源及去掉表别名后的路径
+// join 查询中字段以表名或别名开头，如 p.spec.nodeName
+func fieldResource(k *Kubectl, field string) (schema.GroupVersionKind, string) {
+	filter := &k.Statement.Filter
+	if len(filter.Joins) == 0 {
+		return k.Statement.GVK, field
+	}
+	main := filter.TableAlias
+	if main == "" {
+		main = filter.From
+	}
+	if path, ok := strings.CutPrefix(field, main+"."); ok {
+		return k.Statement.GVK, path
+	}
+	for _, j := range filter.Joins {
+		if path, ok := strings.CutPrefix(field, j.Name()+"."); ok {
+			return j.GVK, path
+		}
+	}
+	return k.Statement.GVK, field
+}
+
+// funcFields 获取函数参数中的字段
+func funcFields(call *FuncCall) []string {
+	var fields []string
+	for _, arg := range call.Args {
+		switch {
+		case arg.Func != nil:
+			fields = append(fields, funcFields(arg.Func)...)
+		case arg.Field != "":
+			fields = append(fields, arg.Field)
+		}
+	}
+	return fields
+}
+
+// orderFields 获取 order by 中的字段
+func orderFields(order string) []string {
+	if strings.TrimSpace(order) == "" {
+		return nil
+	}
+	var fields []string
+	for _, item := range splitTopLevel(order) {
+		parts := strings.Fields(item)
+		if len(parts) == 0 {
+			continue
+		}
+		fields = append(fields, strings.Trim(parts[0], "`"))
+	}
+	return fields
+}
+
+// isResultColumn 是否为查询列的别名或聚合列
+func isResultColumn(filter *Filter, field string) bool {
+	if strings.Contains(field, "(") {
+		return true
+	}
+	for _, c := range filter.Projection {
+		if c.Alias == field || (c.Func != "" && c.Name() == field) {
+			return true
+		}
+	}
+	return false
+}
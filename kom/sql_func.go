@@ -0,0 +1,435 @@
+package kom
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/weibaohui/kom/utils"
+	"github.com/xwb1989/sqlparser"
+	"k8s.io/klog/v2"
+)
+
+// SqlFunc sql 标量函数
+// obj 为当前资源对象，args 为参数值。字段参数已替换为字段值，字段不存在时为 nil，经过数组时为 []interface{}。
+// 参数中不含字段时（如 now()、lower('ABC')），以 nil 对象求值，含 now() 的条件每次判断时重新求值，其余在解析时求值一次。
+// 返回 nil 表示值不存在，返回切片时任一值满足条件即成立。
+type SqlFunc func(obj map[string]interface{}, args []interface{}) (interface{}, error)
+
+var (
+	sqlFuncs = map[string]SqlFunc{
+		"now":         sqlFuncNow,
+		"lower":       sqlFuncString(strings.ToLower),
+		"upper":       sqlFuncString(strings.ToUpper),
+		"len":         sqlFuncLen,
+		"labels":      sqlFuncMetadataKey("labels"),
+		"annotations": sqlFuncMetadataKey("annotations"),
+	}
+	builtinSqlFuncs = map[string]bool{}
+	sqlFuncsLock    sync.RWMutex
+)
+
+func init() {
+	for name := range sqlFuncs {
+		builtinSqlFuncs[name] = true
+	}
+}
+
+// RegisterSqlFunc 注册 sql 标量函数，函数名不区分大小写
+// 内置函数、聚合函数及 any、all 不能被覆盖，其他同名函数会被覆盖
+//
+//	kom.RegisterSqlFunc("trim", func(obj map[string]interface{}, args []interface{}) (interface{}, error) {
+//		return strings.TrimSpace(fmt.Sprintf("%v", args[0])), nil
+//	})
+//	kom.DefaultCluster().Resource(&v1.Pod{}).Where("trim(metadata.labels.app) = 'web'").List(&list)
+func RegisterSqlFunc(name string, fn SqlFunc) error {
+	name = strings.ToLower(name)
+	if name == "" || fn == nil {
+		return fmt.Errorf("sql function name and fn are required")
+	}
+	if builtinSqlFuncs[name] || aggregateFuncs[name] || name == QuantifierAny || name == QuantifierAll || name == quantifierAllFunc {
+		return fmt.Errorf("sql function %s is reserved", name)
+	}
+	sqlFuncsLock.Lock()
+	defer sqlFuncsLock.Unlock()
+	sqlFuncs[name] = fn
+	return nil
+}
+
+// LookupSqlFunc 获取已注册的 sql 标量函数
+func LookupSqlFunc(name string) (SqlFunc, bool) {
+	sqlFuncsLock.RLock()
+	defer sqlFuncsLock.RUnlock()
+	fn, ok := sqlFuncs[strings.ToLower(name)]
+	return fn, ok
+}
+
+// FuncCall 条件中的函数调用，如 lower(metadata.name)、labels('app')
+type FuncCall struct {
+	Name string
+	Args []*FuncArg
+}
+
+// FuncArg 函数参数，Field、Func、Value 三者之一
+type FuncArg struct {
+	Field string      // 字段路径，求值时替换为字段值
+	Func  *FuncCall   // 嵌套函数，如 upper(labels('app'))
+	Value interface{} // 常量
+}
+
+// String 输出函数调用，如 lower(metadata.name)
+func (f *FuncCall) String() string {
+	var args []string
+	for _, arg := range f.Args {
+		switch {
+		case arg.Func != nil:
+			args = append(args, arg.Func.String())
+		case arg.Field != "":
+			args = append(args, arg.Field)
+		default:
+			switch v := arg.Value.(type) {
+			case string:
+				args = append(args, fmt.Sprintf("'%s'", v))
+			case []string:
+				args = append(args, utils.StringListToSQLIn(v))
+			default:
+				args = append(args, fmt.Sprintf("%v", v))
+			}
+		}
+	}
+	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ", "))
+}
+
+// HasField 参数中是否引用了字段
+func (f *FuncCall) HasField() bool {
+	for _, arg := range f.Args {
+		if arg.Field != "" || (arg.Func != nil && arg.Func.HasField()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Eval 对资源对象求值，fieldValue 用于获取字段参数的值
+func (f *FuncCall) Eval(obj map[string]interface{}, fieldValue func(field string) interface{}) (interface{}, error) {
+	fn, ok := LookupSqlFunc(f.Name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported function: %s", f.Name)
+	}
+	args := make([]interface{}, 0, len(f.Args))
+	for _, arg := range f.Args {
+		switch {
+		case arg.Func != nil:
+			v, err := arg.Func.Eval(obj, fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		case arg.Field != "":
+			if fieldValue == nil {
+				return nil, fmt.Errorf("%s requires a resource object", f.String())
+			}
+			args = append(args, fieldValue(arg.Field))
+		default:
+			args = append(args, arg.Value)
+		}
+	}
+	return fn(obj, args)
+}
+
+// funcCall 解析函数调用
+func (p *whereParser) funcCall(fn *sqlparser.FuncExpr) (*FuncCall, error) {
+	name := fn.Name.Lowered()
+	if _, ok := LookupSqlFunc(name); !ok || fn.Distinct || !fn.Qualifier.IsEmpty() {
+		return nil, fmt.Errorf("unsupported function: %s", sqlparser.String(fn))
+	}
+	call := &FuncCall{Name: name}
+	for _, expr := range fn.Exprs {
+		arg, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, fmt.Errorf("unsupported function argument: %s", sqlparser.String(fn))
+		}
+		switch e := arg.Expr.(type) {
+		case *sqlparser.ColName:
+			call.Args = append(call.Args, &FuncArg{Field: fieldPath(e)})
+		case *sqlparser.FuncExpr:
+			inner, err := p.funcCall(e)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, &FuncArg{Func: inner})
+		default:
+			v, err := p.constValue(e)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, &FuncArg{Value: v})
+		}
+	}
+	return call, nil
+}
+
+// value 获取条件右侧的值
+// 函数及时间运算在解析时求值，如 now() - interval 7 day，含 now() 的条件在判断时重新求值，其余按常量及绑定参数处理
+func (p *whereParser) value(expr sqlparser.Expr) (interface{}, error) {
+	switch expr.(type) {
+	case *sqlparser.FuncExpr, *sqlparser.BinaryExpr, *sqlparser.IntervalExpr:
+		v, err := p.constValue(expr)
+		if err != nil {
+			return nil, err
+		}
+		if interval, ok := v.(sqlInterval); ok {
+			return interval.duration().String(), nil
+		}
+		return boundValue(v), nil
+	}
+	return p.args.literal(expr)
+}
+
+// constValue 计算常量表达式，支持函数、interval 以及时间的加减
+func (p *whereParser) constValue(expr sqlparser.Expr) (interface{}, error) {
+	switch e := expr.(type) {
+	case *sqlparser.ParenExpr:
+		return p.constValue(e.Expr)
+	case *sqlparser.FuncExpr:
+		call, err := p.funcCall(e)
+		if err != nil {
+			return nil, err
+		}
+		if call.HasField() {
+			return nil, fmt.Errorf("function on the right side cannot reference fields: %s", sqlparser.String(e))
+		}
+		return call.Eval(nil, nil)
+	case *sqlparser.IntervalExpr:
+		n, err := p.args.literal(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return newSqlInterval(fmt.Sprintf("%v", n), e.Unit)
+	case *sqlparser.BinaryExpr:
+		if e.Operator != sqlparser.PlusStr && e.Operator != sqlparser.MinusStr {
+			return nil, fmt.Errorf("unsupported operator %s: %s", e.Operator, sqlparser.String(e))
+		}
+		left, err := p.constValue(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.constValue(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := left.(time.Time)
+		interval, isInterval := right.(sqlInterval)
+		if !ok || !isInterval {
+			return nil, fmt.Errorf("only time +/- interval is supported: %s", sqlparser.String(e))
+		}
+		if e.Operator == sqlparser.MinusStr {
+			return interval.subtractFrom(t), nil
+		}
+		return interval.addTo(t), nil
+	case *sqlparser.ColName:
+		return nil, fmt.Errorf("field is not allowed here: %s", sqlparser.String(e))
+	}
+	return p.args.literal(expr)
+}
+
+// usesNow 表达式中是否调用了 now()，此类条件值随时间变化
+func usesNow(expr sqlparser.Expr) bool {
+	found := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if fn, ok := node.(*sqlparser.FuncExpr); ok && fn.Name.Lowered() == "now" {
+			found = true
+			return false, nil
+		}
+		return !found, nil
+	}, expr)
+	return found
+}
+
+// TimeRelative 条件值是否与当前时间相关，如 metadata.creationTimestamp > now() - interval 1 hour
+func (c *Condition) TimeRelative() bool {
+	return c.refresh != nil
+}
+
+// Refreshed 返回按当前时间重新计算条件值后的条件，条件值与时间无关时返回自身
+// 过滤时每次判断条件都使用当前时间，持续运行的 WatchQuery 中时间窗口随时间移动
+func (c *Condition) Refreshed() *Condition {
+	if c.refresh == nil {
+		return c
+	}
+	rc := *c
+	if err := c.refresh(&rc); err != nil {
+		klog.V(6).Infof("refresh condition %s error %v", c.Field, err)
+		return c
+	}
+	return &rc
+}
+
+// TimeRelative 表达式树中是否有与当前时间相关的条件
+func (n *WhereNode) TimeRelative() bool {
+	if n == nil {
+		return false
+	}
+	if n.Condition != nil && n.Condition.TimeRelative() {
+		return true
+	}
+	for _, child := range n.Children {
+		if child.TimeRelative() {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlInterval 时间间隔，如 interval 7 day
+// 月、年按日历计算，其余按固定时长计算
+type sqlInterval struct {
+	months int
+	length time.Duration
+}
+
+// 时间间隔单位
+var intervalUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// newSqlInterval 解析时间间隔，单位支持 second、minute、hour、day、week、month、year 及其复数形式
+func newSqlInterval(n string, unit string) (sqlInterval, error) {
+	count := 0
+	if _, err := fmt.Sscanf(n, "%d", &count); err != nil || fmt.Sprintf("%d", count) != n {
+		return sqlInterval{}, fmt.Errorf("invalid interval %s %s", n, unit)
+	}
+	unit = strings.TrimSuffix(strings.ToLower(unit), "s")
+	switch unit {
+	case "month":
+		return sqlInterval{months: count}, nil
+	case "year":
+		return sqlInterval{months: count * 12}, nil
+	}
+	d, ok := intervalUnits[unit]
+	if !ok {
+		return sqlInterval{}, fmt.Errorf("unsupported interval unit %s", unit)
+	}
+	return sqlInterval{length: time.Duration(count) * d}, nil
+}
+
+func (i sqlInterval) addTo(t time.Time) time.Time {
+	return t.AddDate(0, i.months, 0).Add(i.length)
+}
+
+func (i sqlInterval) subtractFrom(t time.Time) time.Time {
+	return t.AddDate(0, -i.months, 0).Add(-i.length)
+}
+
+// duration 单独使用 interval 时转换为时长，月按30天计算
+func (i sqlInterval) duration() time.Duration {
+	return time.Duration(i.months)*30*24*time.Hour + i.length
+}
+
+// 引号形式的时间间隔，如 interval '7 day'
+var quotedIntervalPattern = regexp.MustCompile(`(?i)\binterval\s+'\s*(\d+)\s*([a-z]+)\s*'`)
+
+// rewriteInterval 将 interval '7 day' 改写为 sqlparser 支持的 interval 7 day
+func rewriteInterval(sql string) string {
+	masked := maskQuoted(sql)
+	var sb strings.Builder
+	last := 0
+	for _, m := range quotedIntervalPattern.FindAllStringSubmatchIndex(sql, -1) {
+		// interval 关键字位于引号内时不处理
+		if !strings.EqualFold(masked[m[0]:m[0]+len("interval")], "interval") {
+			continue
+		}
+		sb.WriteString(sql[last:m[0]])
+		sb.WriteString(fmt.Sprintf("interval %s %s", sql[m[2]:m[3]], sql[m[4]:m[5]]))
+		last = m[1]
+	}
+	sb.WriteString(sql[last:])
+	return sb.String()
+}
+
+// sqlFuncNow now() 当前时间
+func sqlFuncNow(obj map[string]interface{}, args []interface{}) (interface{}, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("now() takes no arguments")
+	}
+	return time.Now(), nil
+}
+
+// sqlFuncString 字符串转换函数，如 lower、upper，数组逐项转换
+func sqlFuncString(convert func(string) string) SqlFunc {
+	return func(obj map[string]interface{}, args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("string function requires exactly one argument")
+		}
+		switch v := args[0].(type) {
+		case nil:
+			return nil, nil
+		case []interface{}:
+			values := make([]interface{}, 0, len(v))
+			for _, item := range v {
+				values = append(values, convert(fmt.Sprintf("%v", item)))
+			}
+			return values, nil
+		case []string:
+			values := make([]interface{}, 0, len(v))
+			for _, item := range v {
+				values = append(values, convert(item))
+			}
+			return values, nil
+		}
+		return convert(fmt.Sprintf("%v", args[0])), nil
+	}
+}
+
+// sqlFuncLen len() 数组、map 的元素个数或字符串长度，字段不存在时为0
+func sqlFuncLen(obj map[string]interface{}, args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("len() requires exactly one argument")
+	}
+	switch v := args[0].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return utf8.RuneCountInString(v), nil
+	}
+	rv := reflect.ValueOf(args[0])
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), nil
+	}
+	return utf8.RuneCountInString(fmt.Sprintf("%v", args[0])), nil
+}
+
+// sqlFuncMetadataKey 获取 metadata 中 map 字段的值，如 labels('app.kubernetes.io/name')
+// 用于 key 中含有 . 或 / 的标签、注解
+func sqlFuncMetadataKey(field string) SqlFunc {
+	return func(obj map[string]interface{}, args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s() requires exactly one key", field)
+		}
+		key, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s() key must be a string", field)
+		}
+		if obj == nil {
+			return nil, fmt.Errorf("%s() requires a resource object", field)
+		}
+		metadata, ok := obj["metadata"].(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		values, ok := metadata[field].(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return values[key], nil
+	}
+}
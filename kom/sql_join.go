@@ -0,0 +1,126 @@
+package kom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// sqlTable from 子句中的表
+type sqlTable struct {
+	cluster string // 集群限定，如 prod.pod 中的 prod
+	name    string
+	alias   string
+}
+
+// aliasOrName 表在结果中的名称
+func (t *sqlTable) aliasOrName() string {
+	if t.alias != "" {
+		return t.alias
+	}
+	return t.name
+}
+
+// parseFrom 解析 from 子句，返回主表以及按顺序 join 的表
+// 支持 join（inner join）及 left join，连接条件只支持字段间的等值比较，多个条件使用 and 连接
+// select pod.metadata.name, node.metadata.labels.zone from pod join node on pod.spec.nodeName = node.metadata.name
+func parseFrom(from sqlparser.TableExprs) (*sqlTable, []*Join, error) {
+	if len(from) != 1 {
+		return nil, nil, fmt.Errorf("only one table is supported in from, use join instead: %s", sqlparser.String(from))
+	}
+	var joins []*Join
+	base, err := parseTableExpr(from[0], &joins)
+	if err != nil {
+		return nil, nil, err
+	}
+	return base, joins, nil
+}
+
+// parseTableExpr 递归解析表达式，join 的表按照出现顺序追加到 joins 中，返回最左侧的主表
+func parseTableExpr(expr sqlparser.TableExpr, joins *[]*Join) (*sqlTable, error) {
+	switch node := expr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		tableName, ok := node.Expr.(sqlparser.TableName)
+		if !ok {
+			return nil, fmt.Errorf("unsupported table expression: %s", sqlparser.String(node))
+		}
+		return &sqlTable{
+			cluster: tableName.Qualifier.String(),
+			name:    tableName.Name.String(),
+			alias:   node.As.String(),
+		}, nil
+	case *sqlparser.ParenTableExpr:
+		if len(node.Exprs) != 1 {
+			return nil, fmt.Errorf("unsupported table expression: %s", sqlparser.String(node))
+		}
+		return parseTableExpr(node.Exprs[0], joins)
+	case *sqlparser.JoinTableExpr:
+		if node.Join != sqlparser.JoinStr && node.Join != sqlparser.LeftJoinStr {
+			return nil, fmt.Errorf("unsupported join type %s, only join and left join are supported", node.Join)
+		}
+		base, err := parseTableExpr(node.LeftExpr, joins)
+		if err != nil {
+			return nil, err
+		}
+		right, ok := node.RightExpr.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return nil, fmt.Errorf("unsupported join table expression: %s", sqlparser.String(node.RightExpr))
+		}
+		table, err := parseTableExpr(right, joins)
+		if err != nil {
+			return nil, err
+		}
+		if table.cluster != "" {
+			// join 的表与主表在同一集群中查询，跨集群查询时各集群分别执行 join
+			return nil, fmt.Errorf("join table does not support cluster qualifier, it is queried in the cluster of the main table: %s", sqlparser.String(node))
+		}
+		join := &Join{
+			Type:  node.Join,
+			Table: table.name,
+			Alias: table.alias,
+		}
+		if join.On, err = parseJoinOn(join.Name(), node.Condition.On); err != nil {
+			return nil, err
+		}
+		*joins = append(*joins, join)
+		return base, nil
+	}
+	return nil, fmt.Errorf("unsupported table expression: %s", sqlparser.String(expr))
+}
+
+// parseJoinOn 解析 join 的连接条件
+// 条件两侧中以当前表名开头的一侧作为 Right，另一侧作为 Left
+func parseJoinOn(name string, expr sqlparser.Expr) ([]*JoinOn, error) {
+	switch node := expr.(type) {
+	case *sqlparser.AndExpr:
+		left, err := parseJoinOn(name, node.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := parseJoinOn(name, node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	case *sqlparser.ParenExpr:
+		return parseJoinOn(name, node.Expr)
+	case *sqlparser.ComparisonExpr:
+		if node.Operator != sqlparser.EqualStr {
+			break
+		}
+		left, right := fieldPath(node.Left), fieldPath(node.Right)
+		prefix := name + "."
+		if strings.HasPrefix(left, prefix) {
+			left, right = right, left
+		}
+		if !strings.HasPrefix(right, prefix) {
+			return nil, fmt.Errorf("join condition %s must reference table %s", sqlparser.String(node), name)
+		}
+		return []*JoinOn{{
+			Left:  left,
+			Right: strings.TrimPrefix(right, prefix),
+		}}, nil
+	}
+	return nil, fmt.Errorf("unsupported join condition: %s, only equality conditions joined by and are supported", sqlparser.String(expr))
+}
@@ -3,56 +3,545 @@ package kom
 import (
 	"fmt"
 	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/weibaohui/kom/utils"
 	"github.com/xwb1989/sqlparser"
 	"k8s.io/klog/v2"
 )
 
+// whereParser 解析 WHERE 表达式
+// 同时生成表达式树与扁平的条件列表，扁平列表用于兼容 Filter.Conditions
+type whereParser struct {
+	conditions []*Condition
+	args       *sqlArgs // 绑定的参数
+}
+
+// parseWhere 解析 WHERE 语句，返回表达式树及扁平的条件列表
+// where 为空时返回 nil 表达式树，遇到不支持的操作符或表达式时返回错误
+func parseWhere(where *sqlparser.Where, args *sqlArgs) (*WhereNode, []*Condition, error) {
+	p := &whereParser{args: args}
+	if where == nil || where.Expr == nil {
+		return nil, p.conditions, nil
+	}
+	node, err := p.parse(0, "AND", where.Expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 探测 conditions中的条件值类型，绑定参数已按参数类型赋值
+	for _, cond := range p.conditions {
+		if _, isList := cond.Value.([]string); cond.Value == nil || isList || cond.ValueType != "" {
+			continue
+		}
+		cond.ValueType, cond.Value = utils.DetectType(cond.Value)
+	}
+	return node, p.conditions, nil
+}
+
+// 支持的比较操作符
+var whereOperators = []string{
+	sqlparser.EqualStr, sqlparser.NotEqualStr,
+	sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr, sqlparser.GreaterEqualStr,
+	sqlparser.LikeStr, sqlparser.NotLikeStr,
+	sqlparser.InStr, sqlparser.NotInStr,
+	sqlparser.RegexpStr, sqlparser.NotRegexpStr,
+}
+
+// 比较大小的操作符，字符串参数按文本探测类型
+var orderedOperators = []string{
+	sqlparser.LessThanStr, sqlparser.GreaterThanStr, sqlparser.LessEqualStr, sqlparser.GreaterEqualStr,
+}
+
 // 解析 WHERE 表达式
-func parseWhereExpr(conditions []*Condition, depth int, andor string, expr sqlparser.Expr) []*Condition {
+func (p *whereParser) parse(depth int, andor string, expr sqlparser.Expr) (*WhereNode, error) {
 	klog.V(6).Infof("expr type [%v],string %s, type [%s]", reflect.TypeOf(expr), sqlparser.String(expr), andor)
 	d := depth + 1 // 深度递增
 	switch node := expr.(type) {
 	case *sqlparser.ComparisonExpr:
 		// 处理比较表达式 (比如 age > 80)
-		cond := &Condition{
-			Depth:    depth,
-			AndOr:    andor,
-			Field:    utils.TrimQuotes(sqlparser.String(node.Left)),
-			Operator: node.Operator,
-			Value:    utils.TrimQuotes(sqlparser.String(node.Right)),
-		}
-		conditions = append(conditions, cond)
+		if !slices.Contains(whereOperators, node.Operator) {
+			return nil, fmt.Errorf("unsupported operator %s: %s", node.Operator, sqlparser.String(expr))
+		}
+		cond, err := p.conditionField(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		cond.Depth = depth
+		cond.AndOr = andor
+		cond.Operator = node.Operator
+		if sub, ok := node.Right.(*sqlparser.Subquery); ok {
+			// 子查询，如 spec.nodeName in (select metadata.name from node)，在执行查询前求值
+			if node.Operator != sqlparser.InStr && node.Operator != sqlparser.NotInStr {
+				return nil, fmt.Errorf("subquery only supports in and not in: %s", sqlparser.String(expr))
+			}
+			cond.Subquery = sqlparser.String(sub.Select)
+			cond.SubqueryArgs = p.args.subset(sub.Select)
+			return p.leaf(cond), nil
+		}
+		// 右侧的函数及时间运算在解析时求值，如 now() - interval 7 day，含 now() 时判断条件前按当前时间重新求值
+		if arg, ok := node.Right.(*sqlparser.SQLVal); ok && arg.Type == sqlparser.ValArg {
+			// 绑定参数按参数的类型比较，=、!=、like、in 的字符串参数不会被识别为数字、布尔值
+			ordered := slices.Contains(orderedOperators, node.Operator)
+			if cond.ValueType, cond.Value, err = p.args.typed(arg, ordered); err != nil {
+				return nil, err
+			}
+		} else if cond.Value, err = p.value(node.Right); err != nil {
+			return nil, err
+		} else if usesNow(node.Right) {
+			right := node.Right
+			cond.refresh = func(c *Condition) error {
+				v, err := p.value(right)
+				if err != nil {
+					return err
+				}
+				c.ValueType, c.Value = utils.DetectType(v)
+				return nil
+			}
+		}
+		if node.Operator == sqlparser.RegexpStr || node.Operator == sqlparser.NotRegexpStr {
+			// 正则表达式在解析时编译，错误的表达式直接返回错误
+			if cond.Pattern, err = regexp.Compile(fmt.Sprintf("%v", cond.Value)); err != nil {
+				return nil, fmt.Errorf("invalid regexp %s: %v", sqlparser.String(node.Right), err)
+			}
+		}
+		return p.leaf(cond), nil
+	case *sqlparser.IsExpr:
+		// 处理 is null、is not null，字段不存在即为 null
+		if node.Operator != sqlparser.IsNullStr && node.Operator != sqlparser.IsNotNullStr {
+			return nil, fmt.Errorf("unsupported operator %s: %s", node.Operator, sqlparser.String(expr))
+		}
+		cond, err := p.conditionField(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Quantifier != "" {
+			return nil, fmt.Errorf("%s does not support %s(): %s", node.Operator, cond.Quantifier, sqlparser.String(expr))
+		}
+		cond.Depth = depth
+		cond.AndOr = andor
+		cond.Operator = node.Operator
+		return p.leaf(cond), nil
 	case *sqlparser.ParenExpr:
 		// 处理括号表达式
 		// 括号内的表达式是一个独立的子表达式，增加深度
-		conditions = parseWhereExpr(conditions, d+1, "AND", node.Expr)
-
+		return p.parse(d+1, "AND", node.Expr)
 	case *sqlparser.AndExpr:
 		// 递归解析 AND 表达式
 		// 这里传递 "AND" 给左右两边
-		conditions = parseWhereExpr(conditions, d, "AND", node.Left)
-		conditions = parseWhereExpr(conditions, d, "AND", node.Right)
-	case *sqlparser.RangeCond:
-		// 递归解析 between 1 and 3 表达式
-		cond := &Condition{
-			Depth:    depth,
-			AndOr:    andor,
-			Field:    utils.TrimQuotes(sqlparser.String(node.Left)),                                                                        // 左侧的字段
-			Operator: node.Operator,                                                                                                        // 操作符（BETWEEN）
-			Value:    fmt.Sprintf("%s and %s", utils.TrimQuotes(sqlparser.String(node.From)), utils.TrimQuotes(sqlparser.String(node.To))), // 范围值
-		}
-		conditions = append(conditions, cond)
+		left, err := p.parse(d, "AND", node.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.parse(d, "AND", node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return newLogicNode(WhereOpAnd, left, right), nil
 	case *sqlparser.OrExpr:
 		// 递归解析 OR 表达式
 		// 这里传递 "OR" 给左右两边
-		conditions = parseWhereExpr(conditions, d, "OR", node.Left)
-		conditions = parseWhereExpr(conditions, d, "OR", node.Right)
+		left, err := p.parse(d, "OR", node.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.parse(d, "OR", node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return newLogicNode(WhereOpOr, left, right), nil
+	case *sqlparser.NotExpr:
+		// NOT 表达式，对子表达式取反
+		child, err := p.parse(d, andor, node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &WhereNode{Op: WhereOpNot, Children: []*WhereNode{child}}, nil
+	case *sqlparser.RangeCond:
+		// 递归解析 between 1 and 3 表达式
+		cond, err := p.conditionField(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.setRange(cond, node.From, node.To); err != nil {
+			return nil, err
+		}
+		cond.Depth = depth
+		cond.AndOr = andor
+		cond.Operator = node.Operator // 操作符（BETWEEN）
+		if usesNow(node.From) || usesNow(node.To) {
+			from, to := node.From, node.To
+			cond.refresh = func(c *Condition) error {
+				return p.setRange(c, from, to)
+			}
+		}
+		return p.leaf(cond), nil
+	}
+	// 其他表达式
+	return nil, fmt.Errorf("unsupported expression: %s", sqlparser.String(expr))
+}
 
+// setRange 设置 between 的范围值
+func (p *whereParser) setRange(cond *Condition, fromExpr, toExpr sqlparser.Expr) error {
+	fromType, from, err := p.rangeValue(fromExpr)
+	if err != nil {
+		return err
+	}
+	_, to, err := p.rangeValue(toExpr)
+	if err != nil {
+		return err
+	}
+	cond.From, cond.To = from, to // 范围值
+	cond.Value = fmt.Sprintf("%v and %v", from, to)
+	cond.ValueType = fromType
+	return nil
+}
+
+// rangeValue 获取 between 的范围值及类型，绑定参数按参数的类型，字符串参数及其余值按文本探测类型
+func (p *whereParser) rangeValue(expr sqlparser.Expr) (string, interface{}, error) {
+	if arg, ok := expr.(*sqlparser.SQLVal); ok && arg.Type == sqlparser.ValArg {
+		return p.args.typed(arg, true)
+	}
+	v, err := p.value(expr)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, isList := v.([]string); isList {
+		return "", nil, fmt.Errorf("between does not support list values: %s", sqlparser.String(expr))
+	}
+	valueType, value := utils.DetectType(v)
+	return valueType, value, nil
+}
+
+// conditionField 解析条件左侧的字段
+// 支持数组量词 any(spec.containers.image)、all(spec.containers.image)，
+// 以及标量函数 lower(metadata.name)、labels('app')，函数在过滤时按资源对象求值
+func (p *whereParser) conditionField(expr sqlparser.Expr) (*Condition, error) {
+	fn, ok := expr.(*sqlparser.FuncExpr)
+	if !ok {
+		return &Condition{Field: fieldPath(expr)}, nil
+	}
+	var quantifier string
+	switch name := fn.Name.Lowered(); {
+	case name == QuantifierAny:
+		quantifier = QuantifierAny
+	case name == quantifierAllFunc:
+		quantifier = QuantifierAll
+	case aggregateFuncs[name]:
+		// 聚合函数，如 having count(*) > 1
+		if _, _, err := parseAggregate(fn); err != nil {
+			return nil, err
+		}
+		return &Condition{Field: fieldPath(expr)}, nil
 	default:
-		// 其他表达式
-		fmt.Printf("Unhandled expression at depth %d: %s\n", depth, sqlparser.String(expr))
+		call, err := p.funcCall(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &Condition{Field: call.String(), Func: call}, nil
+	}
+	if len(fn.Exprs) != 1 {
+		return nil, fmt.Errorf("%s() requires exactly one field", quantifier)
+	}
+	arg, ok := fn.Exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %s() argument: %s", quantifier, sqlparser.String(fn.Exprs[0]))
+	}
+	return &Condition{Field: fieldPath(arg.Expr), Quantifier: quantifier}, nil
+}
+
+// leaf 记录条件并生成叶子节点
+func (p *whereParser) leaf(cond *Condition) *WhereNode {
+	p.conditions = append(p.conditions, cond)
+	return &WhereNode{Op: WhereOpCondition, Condition: cond}
+}
+
+// newLogicNode 生成 AND/OR 节点
+// 相同类型的子节点会被展开合并，a and (b and c) 等价于 a and b and c
+func newLogicNode(op string, children ...*WhereNode) *WhereNode {
+	node := &WhereNode{Op: op}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if child.Op == op {
+			node.Children = append(node.Children, child.Children...)
+			continue
+		}
+		node.Children = append(node.Children, child)
+	}
+	switch len(node.Children) {
+	case 0:
+		return nil
+	case 1:
+		return node.Children[0]
+	}
+	return node
+}
+
+// WhereTree 返回where条件表达式树
+// 通过Sql、Where解析得到的条件直接使用表达式树。
+// 如果只设置了扁平的Conditions（未经过解析），则按照 and/or 标记组装：
+// 所有AND条件必须满足，OR条件中至少满足一个
+func (f *Filter) WhereTree() *WhereNode {
+	if f.Expr != nil {
+		return f.Expr
+	}
+	var ands, ors []*WhereNode
+	for _, cond := range f.Conditions {
+		leaf := &WhereNode{Op: WhereOpCondition, Condition: cond}
+		if cond.AndOr == WhereOpOr {
+			ors = append(ors, leaf)
+		} else {
+			ands = append(ands, leaf)
+		}
+	}
+	return newLogicNode(WhereOpAnd, append(ands, newLogicNode(WhereOpOr, ors...))...)
+}
+
+// parseSelectExprs 解析 select 查询列
+// select * 返回空列表，表示查询完整对象
+// 支持聚合函数 count、sum、avg、min、max
+func parseSelectExprs(exprs sqlparser.SelectExprs) ([]*Column, error) {
+	var columns []*Column
+	for _, expr := range exprs {
+		switch node := expr.(type) {
+		case *sqlparser.StarExpr:
+			// select * ，查询完整对象
+			return nil, nil
+		case *sqlparser.AliasedExpr:
+			var field, fn string
+			switch e := node.Expr.(type) {
+			case *sqlparser.ColName, *sqlparser.SQLVal:
+				field = fieldPath(e)
+			case *sqlparser.FuncExpr:
+				var err error
+				if fn, field, err = parseAggregate(e); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unsupported select expression: %s", sqlparser.String(node.Expr))
+			}
+			columns = append(columns, &Column{
+				Field: field,
+				Alias: node.As.String(),
+				Func:  fn,
+			})
+		default:
+			return nil, fmt.Errorf("unsupported select expression: %s", sqlparser.String(expr))
+		}
+	}
+	return columns, nil
+}
+
+// fieldPath 获取字段路径
+// sqlparser 会将 status 等关键字加上反引号，如 `status`.phase，这里按照各级名称重新拼接，得到 status.phase
+func fieldPath(expr sqlparser.Expr) string {
+	if fn, ok := expr.(*sqlparser.FuncExpr); ok {
+		// 聚合函数，如 having count(*) > 1、order by sum(spec.replicas)
+		if name, field, err := parseAggregate(fn); err == nil {
+			return AggregateName(name, field)
+		}
+	}
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return utils.TrimQuotes(sqlparser.String(expr))
+	}
+	var parts []string
+	for _, part := range []string{col.Qualifier.Qualifier.String(), col.Qualifier.Name.String(), col.Name.String()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// 字段路径，如 spec.containers.image、status.addresses[type=InternalIP].address
+var fieldPathPattern = regexp.MustCompile(`^[A-Za-z_]\w*(\[[^\]]*\])?(\.[A-Za-z_]\w*(\[[^\]]*\])?)+`)
+
+// normalizeSql 预处理sql
+// sqlparser 最多只支持三级的名称（db.table.column），k8s 中的字段路径往往超过三级，
+// 这里将超过三级或带有数组筛选条件的字段路径用反引号包裹，引号内的内容保持不变。
+// 同时将 interval '7 day' 改写为 interval 7 day
+func normalizeSql(sql string) string {
+	sql = rewriteInterval(sql)
+	var sb strings.Builder
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		// 跳过引号内的内容
+		if c == '\'' || c == '"' || c == '`' {
+			end := strings.IndexByte(sql[i+1:], c)
+			if end == -1 {
+				sb.WriteString(sql[i:])
+				break
+			}
+			sb.WriteString(sql[i : i+end+2])
+			i += end + 2
+			continue
+		}
+		// 只在标识符的起始位置进行匹配
+		if isIdentStart(c) && (i == 0 || !isIdentChar(sql[i-1])) {
+			// all 为关键字，all(...) 改写为 __all(...)，作为数组量词函数解析
+			if n := quantifierAllLen(sql[i:]); n > 0 {
+				sb.WriteString(quantifierAllFunc)
+				i += n
+				continue
+			}
+			if path := fieldPathPattern.FindString(sql[i:]); path != "" {
+				if strings.Count(path, ".") >= 3 || strings.Contains(path, "[") {
+					sb.WriteString("`" + path + "`")
+				} else {
+					sb.WriteString(path)
+				}
+				i += len(path)
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	return sb.String()
+}
+
+// all 量词改写后的函数名
+const quantifierAllFunc = "__all"
+
+// quantifierAllLen 判断是否以 all( 开头，返回 all 的长度，不是则返回0
+func quantifierAllLen(s string) int {
+	if len(s) < 3 || !strings.EqualFold(s[:3], "all") {
+		return 0
+	}
+	rest := strings.TrimLeft(s[3:], " \t")
+	if rest == "" || rest[0] != '(' || (len(s) > 3 && isIdentChar(s[3])) {
+		return 0
+	}
+	return 3
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '.'
+}
+
+// 支持的聚合函数
+var aggregateFuncs = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// AggregateName 聚合列的默认列名，如 count(*)、sum(spec.replicas)
+func AggregateName(fn string, field string) string {
+	return fmt.Sprintf("%s(%s)", fn, field)
+}
+
+// parseAggregate 解析聚合函数，返回函数名及字段路径
+func parseAggregate(expr *sqlparser.FuncExpr) (string, string, error) {
+	name := expr.Name.Lowered()
+	if !aggregateFuncs[name] {
+		return "", "", fmt.Errorf("unsupported function: %s", sqlparser.String(expr))
+	}
+	if len(expr.Exprs) != 1 || expr.Distinct {
+		return "", "", fmt.Errorf("aggregate function %s requires exactly one argument", sqlparser.String(expr))
+	}
+	switch arg := expr.Exprs[0].(type) {
+	case *sqlparser.StarExpr:
+		if name != "count" {
+			return "", "", fmt.Errorf("only count supports *: %s", sqlparser.String(expr))
+		}
+		return name, "*", nil
+	case *sqlparser.AliasedExpr:
+		return name, fieldPath(arg.Expr), nil
+	}
+	return "", "", fmt.Errorf("unsupported function argument: %s", sqlparser.String(expr))
+}
+
+// parseGroupBy 解析 group by 字段
+func parseGroupBy(groupBy sqlparser.GroupBy) []string {
+	var fields []string
+	for _, expr := range groupBy {
+		fields = append(fields, fieldPath(expr))
+	}
+	return fields
+}
+
+var nullsOrderPattern = regexp.MustCompile(`(?i)\s+nulls\s+(first|last)\s*$`)
+var orderByPattern = regexp.MustCompile(`(?i)\border\s+by\b`)
+var limitPattern = regexp.MustCompile(`(?i)\blimit\b`)
+
+// extractNullsOrder 提取 order by 中的 nulls first、nulls last
+// sqlparser 不支持该语法，解析前需要去掉，返回去掉后的sql，以及每个排序字段对应的 nulls 设置
+func extractNullsOrder(sql string) (string, []string) {
+	loc := orderByPattern.FindAllStringIndex(maskQuoted(sql), -1)
+	if len(loc) == 0 {
+		return sql, nil
+	}
+	start := loc[len(loc)-1][1]
+	end := len(sql)
+	if l := limitPattern.FindStringIndex(maskQuoted(sql[start:])); l != nil {
+		end = start + l[0]
+	}
+
+	var items, nulls []string
+	found := false
+	for _, item := range splitTopLevel(sql[start:end]) {
+		n := ""
+		if m := nullsOrderPattern.FindStringSubmatch(item); m != nil {
+			n = "nulls " + strings.ToLower(m[1])
+			item = nullsOrderPattern.ReplaceAllString(item, "")
+			found = true
+		}
+		items = append(items, item)
+		nulls = append(nulls, n)
+	}
+	if !found {
+		return sql, nil
+	}
+	return sql[:start] + strings.Join(items, ",") + " " + sql[end:], nulls
+}
+
+// maskQuoted 将引号内的内容替换为空格，便于在不受引号内容影响的情况下查找关键字，长度保持不变
+func maskQuoted(sql string) string {
+	b := []byte(sql)
+	var quote byte
+	for i, c := range b {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				b[i] = ' '
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		}
+	}
+	return string(b)
+}
+
+// splitTopLevel 按逗号分割，忽略括号及引号内的逗号
+func splitTopLevel(s string) []string {
+	var parts []string
+	masked := maskQuoted(s)
+	depth, last := 0, 0
+	for i := 0; i < len(masked); i++ {
+		switch masked[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
 	}
-	return conditions
+	return append(parts, s[last:])
 }
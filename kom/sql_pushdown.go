@@ -0,0 +1,418 @@
+package kom
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// PushdownPlan 谓词下推计划
+// 将 where 中可以由 API Server 处理的条件转换为按命名空间查询、LabelSelector、FieldSelector，
+// 其余条件在获取列表后在内存中计算。
+// 只有位于顶层 and 中的条件才会下推，or、not 中的条件全部在内存中计算。
+// 只有选择器的匹配结果与内存中计算一致时才会下推：内存中 = 不区分大小写，数字、时间等按类型比较，
+// 选择器则按原文精确匹配，不一致的条件保留在内存中计算。
+type PushdownPlan struct {
+	Clusters      []string     `json:"clusters,omitempty"`      // 跨集群查询时需要查询的集群，已按 cluster 条件过滤
+	ByNamespace   bool         `json:"byNamespace,omitempty"`   // 是否按命名空间分别查询
+	Namespaces    []string     `json:"namespaces,omitempty"`    // 需要查询的命名空间，ByNamespace 为 true 时有效，为空表示没有符合条件的命名空间
+	LabelSelector string       `json:"labelSelector,omitempty"` // 下推的标签选择器
+	FieldSelector string       `json:"fieldSelector,omitempty"` // 下推的字段选择器
+	Pushed        []*Condition `json:"pushed,omitempty"`        // 已下推的条件
+	Residual      *WhereNode   `json:"residual,omitempty"`      // 剩余在内存中计算的条件
+}
+
+// pod 支持的字段选择器
+var podFieldSelectors = []string{"spec.nodeName", "status.phase"}
+
+// pod 的全部状态，status.phase 只会是其中之一
+var podPhases = []string{"Pending", "Running", "Succeeded", "Failed", "Unknown"}
+
+// 名称允许大写字母的资源组，其余资源的名称均为小写的 DNS 子域名
+var caseSensitiveNameGroups = []string{"rbac.authorization.k8s.io"}
+
+// PushdownPlan 根据当前语句的 where 条件生成谓词下推计划
+func (k *Kubectl) PushdownPlan() *PushdownPlan {
+	stmt := k.Statement
+	tree := stmt.Filter.WhereTree()
+	plan := &PushdownPlan{Residual: tree, Clusters: stmt.targetClusters()}
+	// join 查询的字段路径以表名开头，不进行下推
+	if tree == nil || len(stmt.Filter.Joins) > 0 {
+		return plan
+	}
+
+	var conjuncts []*WhereNode
+	if tree.Op == WhereOpAnd {
+		conjuncts = tree.Children
+	} else {
+		conjuncts = []*WhereNode{tree}
+	}
+
+	var labels, fields []string
+	var namespaces, clusters []string
+	var byCluster bool
+	var residual []*WhereNode
+	for _, node := range conjuncts {
+		if stmt.Filter.IsMultiCluster() {
+			if ids, ok := stmt.clusterPredicate(node); ok {
+				if byCluster {
+					clusters = slice.Intersection(clusters, ids)
+				} else {
+					clusters = ids
+				}
+				byCluster = true
+				plan.Pushed = append(plan.Pushed, collectConditions(node)...)
+				continue
+			}
+		}
+		if ns, ok := stmt.namespacePredicate(node); ok {
+			if plan.ByNamespace {
+				namespaces = slice.Intersection(namespaces, ns)
+			} else {
+				namespaces = ns
+			}
+			plan.ByNamespace = true
+			plan.Pushed = append(plan.Pushed, collectConditions(node)...)
+			continue
+		}
+		if node.Op == WhereOpCondition {
+			if selector, exact, ok := labelSelector(node.Condition); ok {
+				labels = append(labels, selector)
+				if exact {
+					plan.Pushed = append(plan.Pushed, node.Condition)
+					continue
+				}
+				// 只下推标签存在的条件，值仍在内存中比较
+				residual = append(residual, node)
+				continue
+			}
+			if selector, ok := stmt.fieldSelector(node.Condition); ok {
+				fields = append(fields, selector)
+				plan.Pushed = append(plan.Pushed, node.Condition)
+				continue
+			}
+		}
+		residual = append(residual, node)
+	}
+
+	if plan.ByNamespace {
+		plan.Namespaces = stmt.scopeNamespaces(namespaces)
+	}
+	if byCluster {
+		plan.Clusters = slice.Intersection(stmt.targetClusters(), clusters)
+	}
+	plan.LabelSelector = strings.Join(labels, ",")
+	plan.FieldSelector = strings.Join(fields, ",")
+	plan.Residual = newLogicNode(WhereOpAnd, residual...)
+	return plan
+}
+
+// ApplyTo 将下推的选择器合并到 ListOptions 中
+func (p *PushdownPlan) ApplyTo(opts metav1.ListOptions) metav1.ListOptions {
+	opts.LabelSelector = mergeSelectors(opts.LabelSelector, p.LabelSelector)
+	opts.FieldSelector = mergeSelectors(opts.FieldSelector, p.FieldSelector)
+	return opts
+}
+
+// Explain 输出下推计划，说明哪些条件由 API Server 处理，哪些在内存中计算
+func (p *PushdownPlan) Explain() string {
+	var sb strings.Builder
+	sb.WriteString("pushdown:\n")
+	if len(p.Clusters) > 0 {
+		sb.WriteString(fmt.Sprintf("  clusters: [%s]\n", strings.Join(p.Clusters, ", ")))
+	}
+	if p.ByNamespace {
+		sb.WriteString(fmt.Sprintf("  namespaces: [%s]\n", strings.Join(p.Namespaces, ", ")))
+	}
+	if p.LabelSelector != "" {
+		sb.WriteString(fmt.Sprintf("  labelSelector: %s\n", p.LabelSelector))
+	}
+	if p.FieldSelector != "" {
+		sb.WriteString(fmt.Sprintf("  fieldSelector: %s\n", p.FieldSelector))
+	}
+	for _, c := range p.Pushed {
+		sb.WriteString(fmt.Sprintf("  - %s\n", c))
+	}
+	sb.WriteString("in-memory:\n")
+	if p.Residual != nil {
+		sb.WriteString(fmt.Sprintf("  %s\n", p.Residual))
+	}
+	return sb.String()
+}
+
+// namespacePredicate 判断是否为命名空间条件
+// 支持 metadata.namespace='a'、metadata.namespace in ('a','b')，以及多个等值条件的 or 组合
+func (s *Statement) namespacePredicate(node *WhereNode) ([]string, bool) {
+	if !s.Namespaced {
+		return nil, false
+	}
+	switch node.Op {
+	case WhereOpCondition:
+		c := node.Condition
+		if c.Field != "metadata.namespace" || c.Quantifier != "" {
+			return nil, false
+		}
+		switch c.Operator {
+		case "=":
+			// 命名空间名称为小写的 DNS 标签，不区分大小写比较等价于按小写精确匹配
+			if v, ok := selectorValue(c.Value); ok && len(validation.IsDNS1123Label(strings.ToLower(v))) == 0 {
+				return []string{strings.ToLower(v)}, true
+			}
+		case "in":
+			if values, ok := listValues(c.Value); ok && plainValues(values) {
+				return values, true
+			}
+		}
+	case WhereOpOr:
+		var namespaces []string
+		for _, child := range node.Children {
+			ns, ok := s.namespacePredicate(child)
+			if !ok {
+				return nil, false
+			}
+			namespaces = append(namespaces, ns...)
+		}
+		return slice.Unique(namespaces), true
+	}
+	return nil, false
+}
+
+// scopeNamespaces 与语句中设置的命名空间范围取交集
+func (s *Statement) scopeNamespaces(namespaces []string) []string {
+	if s.AllNamespace {
+		return namespaces
+	}
+	if len(s.NamespaceList) > 1 {
+		return slice.Intersection(namespaces, s.NamespaceList)
+	}
+	ns := s.Namespace
+	if ns == "" {
+		ns = metav1.NamespaceDefault
+	}
+	return slice.Intersection(namespaces, []string{ns})
+}
+
+// labelSelector 将 metadata.labels.xxx、labels('xxx') 的条件转换为标签选择器
+// exact 表示选择器与条件等价，为 false 时选择器只要求标签存在，条件仍需在内存中计算：
+// = 、!= 在内存中不区分大小写，值包含字母时只下推标签存在的条件
+func labelSelector(c *Condition) (selector string, exact bool, ok bool) {
+	key, ok := labelKey(c)
+	if !ok || c.Quantifier != "" || len(validation.IsQualifiedName(key)) > 0 {
+		return "", false, false
+	}
+	switch c.Operator {
+	case "=", "!=":
+		v, ok := selectorValue(c.Value)
+		if !ok || len(validation.IsValidLabelValue(v)) > 0 {
+			return key, false, true
+		}
+		if strings.ToLower(v) != strings.ToUpper(v) {
+			return key, false, true
+		}
+		if c.Operator == "!=" {
+			// 标签选择器中 != 会匹配不存在该标签的对象，而 where 条件要求标签存在
+			return fmt.Sprintf("%s,%s!=%s", key, key, v), true, true
+		}
+		return fmt.Sprintf("%s=%s", key, v), true, true
+	case "in", "not in":
+		values, ok := listValues(c.Value)
+		if !ok || !plainValues(values) {
+			return key, false, true
+		}
+		for _, v := range values {
+			if len(validation.IsValidLabelValue(v)) > 0 {
+				return key, false, true
+			}
+		}
+		if c.Operator == "not in" {
+			return fmt.Sprintf("%s,%s notin (%s)", key, key, strings.Join(values, ",")), true, true
+		}
+		return fmt.Sprintf("%s in (%s)", key, strings.Join(values, ",")), true, true
+	}
+	return "", false, false
+}
+
+// labelKey 获取标签条件的 key
+func labelKey(c *Condition) (string, bool) {
+	if c.Func == nil {
+		return strings.CutPrefix(c.Field, "metadata.labels.")
+	}
+	if c.Func.Name != "labels" || len(c.Func.Args) != 1 {
+		return "", false
+	}
+	key, ok := c.Func.Args[0].Value.(string)
+	return key, ok && key != ""
+}
+
+// fieldSelector 将条件转换为字段选择器
+// 所有资源都支持 metadata.name，Pod 还支持 spec.nodeName、status.phase 的等值条件。
+// 字段选择器中 != 会匹配字段为空的对象，而 where 条件要求字段存在，因此只有 metadata.name 支持 !=
+// 内存中 = 不区分大小写，名称为小写时按小写下推，status.phase 按 Pod 状态的原始写法下推
+func (s *Statement) fieldSelector(c *Condition) (string, bool) {
+	if c.Quantifier != "" {
+		return "", false
+	}
+	supported := false
+	switch c.Operator {
+	case "=":
+		supported = c.Field == "metadata.name" ||
+			(s.GVK.Group == "" && s.GVK.Kind == "Pod" && slice.Contain(podFieldSelectors, c.Field))
+	case "!=":
+		supported = c.Field == "metadata.name"
+	}
+	if !supported {
+		return "", false
+	}
+	v, ok := selectorValue(c.Value)
+	if !ok || strings.ContainsAny(v, ",=!\\") {
+		return "", false
+	}
+	switch {
+	case c.Field == "status.phase":
+		phase, found := slice.FindBy(podPhases, func(_ int, p string) bool { return strings.EqualFold(p, v) })
+		if !found {
+			return "", false
+		}
+		v = phase
+	case strings.ToLower(v) == strings.ToUpper(v):
+		// 不包含字母，大小写不影响匹配
+	case c.Field == "spec.nodeName" || !slice.Contain(caseSensitiveNameGroups, s.GVK.Group):
+		v = strings.ToLower(v)
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("%s%s%s", c.Field, c.Operator, v), true
+}
+
+// selectorValue 条件值转换为选择器中的字符串
+// 只支持字符串，数字、布尔值、时间等在内存中按类型比较（如 '007' = 7），与选择器按原文匹配不一致
+func selectorValue(value interface{}) (string, bool) {
+	v, ok := value.(string)
+	if !ok || v == "" {
+		return "", false
+	}
+	if t, _ := utils.DetectType(v); t != utils.TypeString {
+		return "", false
+	}
+	return v, true
+}
+
+// plainValues 列表中的值是否均为普通字符串，in 在内存中对数字、时间等按类型比较，不能下推
+func plainValues(values []string) bool {
+	for _, v := range values {
+		if t, _ := utils.DetectType(v); t != utils.TypeString {
+			return false
+		}
+	}
+	return true
+}
+
+// listValues 解析 in 条件的值列表，如 ('a', 'b')，以及子查询返回的值列表
+func listValues(value interface{}) ([]string, bool) {
+	if values, ok := value.([]string); ok {
+		// 子查询的结果
+		return values, true
+	}
+	str, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	str = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(str), "("), ")")
+	var values []string
+	for _, v := range strings.Split(str, ",") {
+		v = utils.TrimQuotes(strings.TrimSpace(v))
+		if v == "" {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return values, len(values) > 0
+}
+
+// collectConditions 收集表达式树中的所有条件
+func collectConditions(node *WhereNode) []*Condition {
+	if node == nil {
+		return nil
+	}
+	if node.Condition != nil {
+		return []*Condition{node.Condition}
+	}
+	var conditions []*Condition
+	for _, child := range node.Children {
+		conditions = append(conditions, collectConditions(child)...)
+	}
+	return conditions
+}
+
+// 需要加引号的值
+var quoteValuePattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// String 输出条件，如 metadata.name = 'abc'
+func (c *Condition) String() string {
+	field := c.Field
+	if c.Quantifier != "" {
+		field = fmt.Sprintf("%s(%s)", c.Quantifier, c.Field)
+	}
+	if c.Subquery != "" {
+		return fmt.Sprintf("%s %s (%s)", field, c.Operator, c.Subquery)
+	}
+	if c.From != nil && c.To != nil {
+		return fmt.Sprintf("%s %s %s and %s", field, c.Operator, conditionValue(c.From), conditionValue(c.To))
+	}
+	if c.Value == nil {
+		// is null、is not null
+		return fmt.Sprintf("%s %s", field, c.Operator)
+	}
+	if v, ok := c.Value.(string); ok && (c.Operator == "between" || c.Operator == "not between") {
+		// 未经过解析的范围值，如 1 and 3
+		return fmt.Sprintf("%s %s %s", field, c.Operator, v)
+	}
+	if v, ok := c.Value.(string); ok && c.ValueType == utils.TypeString && quoteValuePattern.MatchString(v) {
+		// 数字文本的字符串绑定参数，如 '123'
+		return fmt.Sprintf("%s %s '%s'", field, c.Operator, v)
+	}
+	return fmt.Sprintf("%s %s %s", field, c.Operator, conditionValue(c.Value))
+}
+
+// conditionValue 输出条件值，字符串、时间等加引号
+func conditionValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		if !quoteValuePattern.MatchString(v) && !strings.HasPrefix(v, "(") {
+			return fmt.Sprintf("'%s'", v)
+		}
+	case resource.Quantity:
+		return fmt.Sprintf("'%s'", v.String())
+	case []string:
+		return utils.StringListToSQLIn(v)
+	case time.Duration, time.Time:
+		return fmt.Sprintf("'%v'", v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// String 输出表达式树，如 (a = 1 or b = 2) and not c = 3
+func (n *WhereNode) String() string {
+	switch n.Op {
+	case WhereOpCondition:
+		return n.Condition.String()
+	case WhereOpNot:
+		return fmt.Sprintf("not (%s)", n.Children[0])
+	}
+	var parts []string
+	for _, child := range n.Children {
+		if child.Op == WhereOpAnd || child.Op == WhereOpOr {
+			parts = append(parts, fmt.Sprintf("(%s)", child))
+		} else {
+			parts = append(parts, child.String())
+		}
+	}
+	return strings.Join(parts, " "+strings.ToLower(n.Op)+" ")
+}
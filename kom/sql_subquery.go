@@ -0,0 +1,102 @@
+package kom
+
+import (
+	"fmt"
+
+	"github.com/duke-git/lancet/v2/slice"
+)
+
+// ResolveSubqueries 执行 where、having 中的子查询，将结果作为 in、not in 的值列表
+// 子查询在同一集群上通过 Sql 执行，只能查询一列，数组字段会展开为多个值。
+// 返回求值后的 Filter 副本，不修改语句中的条件，语句再次执行时重新求值
+func (k *Kubectl) ResolveSubqueries() (Filter, error) {
+	filter := k.Statement.Filter
+	var err error
+	if filter.Expr, err = k.resolveSubqueryNode(filter.Expr); err != nil {
+		return filter, err
+	}
+	if filter.Having, err = k.resolveSubqueryNode(filter.Having); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// resolveSubqueryNode 递归求值表达式树中的子查询
+func (k *Kubectl) resolveSubqueryNode(node *WhereNode) (*WhereNode, error) {
+	if node == nil || !hasSubquery(node) {
+		return node, nil
+	}
+	if node.Op == WhereOpCondition {
+		values, err := k.runSubquery(node.Condition.Subquery, node.Condition.SubqueryArgs)
+		if err != nil {
+			return nil, err
+		}
+		cond := *node.Condition
+		cond.Subquery = ""
+		cond.SubqueryArgs = nil
+		cond.Value = values
+		return &WhereNode{Op: WhereOpCondition, Condition: &cond}, nil
+	}
+	resolved := &WhereNode{Op: node.Op}
+	for _, child := range node.Children {
+		c, err := k.resolveSubqueryNode(child)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Children = append(resolved.Children, c)
+	}
+	return resolved, nil
+}
+
+// hasSubquery 判断表达式树中是否包含子查询
+func hasSubquery(node *WhereNode) bool {
+	if node.Condition != nil {
+		return node.Condition.Subquery != ""
+	}
+	return slice.Some(node.Children, func(_ int, child *WhereNode) bool {
+		return hasSubquery(child)
+	})
+}
+
+// runSubquery 执行子查询，返回查询列的值
+func (k *Kubectl) runSubquery(sql string, args map[string]interface{}) ([]string, error) {
+	cluster := Cluster(k.ID)
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster %s not found", k.ID)
+	}
+	var values []interface{}
+	if len(args) > 0 {
+		values = append(values, args)
+	}
+	tx := cluster.WithContext(k.Statement.Context).WithCache(k.Statement.CacheTTL).Sql(sql, values...)
+	if tx.Error != nil {
+		return nil, fmt.Errorf("subquery %s error: %v", sql, tx.Error)
+	}
+	columns := tx.Statement.Filter.Projection
+	if len(columns) != 1 {
+		return nil, fmt.Errorf("subquery must select exactly one column: %s", sql)
+	}
+
+	var rows []Row
+	if err := tx.List(&rows).Error; err != nil {
+		return nil, fmt.Errorf("subquery %s error: %v", sql, err)
+	}
+
+	name := columns[0].Name()
+	result := make([]string, 0)
+	for _, row := range rows {
+		switch v := row[name].(type) {
+		case nil:
+		case []interface{}:
+			// 数组字段，如 spec.volumes.configMap.name
+			for _, item := range v {
+				if item != nil {
+					result = append(result, fmt.Sprintf("%v", item))
+				}
+			}
+		default:
+			result = append(result, fmt.Sprintf("%v", v))
+		}
+	}
+	return slice.Unique(result), nil
+}
@@ -0,0 +1,66 @@
+package kom
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// 持续查询的事件类型
+const (
+	WatchQueryEnter  = "enter"  // 对象开始满足条件
+	WatchQueryLeave  = "leave"  // 对象不再满足条件，或被删除
+	WatchQueryChange = "change" // 满足条件的对象发生了变化
+)
+
+// WatchQueryEvent 持续查询的事件
+type WatchQueryEvent struct {
+	Type    string                     // enter、leave、change
+	Object  *unstructured.Unstructured // 当前对象，被删除时为删除前的对象
+	Old     *unstructured.Unstructured // 上一次满足条件时的对象，enter 时为 nil
+	Row     Row                        // 按 select 查询列生成的结果行，select * 时为 nil
+	Deleted bool                       // leave 是否由删除引起
+}
+
+// WatchQueryHandler 持续查询的事件处理函数，返回错误时停止查询
+type WatchQueryHandler func(event *WatchQueryEvent) error
+
+// WatchQuery 持续查询
+// 先查询当前满足条件的对象，触发 enter 事件，之后 watch 资源变化，
+// 每个事件都按 where 条件重新计算，对象开始满足条件时触发 enter，不再满足或被删除时触发 leave，
+// 满足条件的对象发生变化时触发 change。
+// where 条件含 now() 时（如 metadata.creationTimestamp > now() - interval 1 hour），每隔 RecheckInterval（默认 30 秒）
+// 按当前时间重新计算已知对象，对象随时间进入、离开结果集时同样触发 enter、leave，可通过 WithRecheckInterval 设置间隔。
+// 调用会阻塞，直到 Context 取消、handler 返回错误或首次查询失败，watch 断开后按退避时间自动重连。
+// 不支持 join、group by、跨集群查询，order by、limit 不生效。
+//
+//	err := kom.DefaultCluster().WithContext(ctx).
+//		Sql("select * from pod where metadata.namespace='prod' and status.containerStatuses.state.waiting.reason='CrashLoopBackOff'").
+//		WatchQuery(func(e *kom.WatchQueryEvent) error {
+//			fmt.Println(e.Type, e.Object.GetNamespace(), e.Object.GetName())
+//			return nil
+//		}).Error
+func (k *Kubectl) WatchQuery(handler WatchQueryHandler) *Kubectl {
+	tx := k.getInstance()
+	if tx.Error != nil {
+		return tx
+	}
+	if handler == nil {
+		tx.Error = fmt.Errorf("WatchQuery 需要传入事件处理函数")
+		return tx
+	}
+	tx.Statement.Dest = handler
+	tx.Error = tx.Callback().WatchQuery().Execute(tx)
+	return tx
+}
+
+// DefaultRecheckInterval WatchQuery 重新计算含 now() 条件的默认间隔
+const DefaultRecheckInterval = 30 * time.Second
+
+// WithRecheckInterval 设置 WatchQuery 重新计算含 now() 条件的间隔
+func (k *Kubectl) WithRecheckInterval(interval time.Duration) *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.RecheckInterval = interval
+	return tx
+}
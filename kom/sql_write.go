@@ -0,0 +1,218 @@
+package kom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weibaohui/kom/utils"
+	"github.com/xwb1989/sqlparser"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/klog/v2"
+)
+
+// sql 语句类型
+const (
+	SqlActionSelect = "select"
+	SqlActionUpdate = "update"
+	SqlActionDelete = "delete"
+)
+
+// SetExpr update 语句中的赋值，如 spec.replicas=0
+type SetExpr struct {
+	Field string      `json:"field"` // 字段路径
+	Value interface{} `json:"value"` // 赋值，为 nil 时删除该字段
+}
+
+// SqlResult update、delete 语句中每个对象的执行结果
+type SqlResult struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Action    string `json:"action"`
+	Error     error  `json:"-"`
+}
+
+// parseUpdate 解析 update 语句
+// update deployment set spec.replicas=0 where metadata.labels.env='dev'
+func (k *Kubectl) parseUpdate(updateStmt *sqlparser.Update, nulls []string, args *sqlArgs) error {
+	if err := k.parseWriteTable(updateStmt.TableExprs, updateStmt.Where, args); err != nil {
+		return err
+	}
+	for _, expr := range updateStmt.Exprs {
+		value, err := setValue(expr.Expr, args)
+		if err != nil {
+			return err
+		}
+		field := fieldPath(expr.Name)
+		if strings.Contains(field, "[") {
+			return fmt.Errorf("update 不支持数组字段: %s", field)
+		}
+		k.Statement.Filter.Set = append(k.Statement.Filter.Set, &SetExpr{Field: field, Value: value})
+	}
+	if err := k.setLimit(updateStmt.Limit, args); err != nil {
+		return err
+	}
+	if updateStmt.OrderBy != nil {
+		k.Statement.Filter.Order = formatOrderBy(updateStmt.OrderBy, nulls)
+	}
+	return nil
+}
+
+// parseDelete 解析 delete 语句
+// delete from pod where status.phase='Failed'
+func (k *Kubectl) parseDelete(deleteStmt *sqlparser.Delete, nulls []string, args *sqlArgs) error {
+	if len(deleteStmt.Targets) > 0 {
+		return fmt.Errorf("delete 不支持多表删除")
+	}
+	if err := k.parseWriteTable(deleteStmt.TableExprs, deleteStmt.Where, args); err != nil {
+		return err
+	}
+	if err := k.setLimit(deleteStmt.Limit, args); err != nil {
+		return err
+	}
+	if deleteStmt.OrderBy != nil {
+		k.Statement.Filter.Order = formatOrderBy(deleteStmt.OrderBy, nulls)
+	}
+	return nil
+}
+
+// parseWriteTable 解析 update、delete 语句的表及 where 条件
+// 必须包含 where 条件，避免误操作全表
+func (k *Kubectl) parseWriteTable(tableExprs sqlparser.TableExprs, where *sqlparser.Where, args *sqlArgs) error {
+	table, joins, err := parseFrom(tableExprs)
+	if err != nil {
+		return err
+	}
+	if len(joins) > 0 {
+		return fmt.Errorf("update、delete 不支持 join")
+	}
+	if table.cluster != "" {
+		return fmt.Errorf("update、delete 不支持跨集群: %s.%s", table.cluster, table.name)
+	}
+	if where == nil {
+		return fmt.Errorf("update、delete 语句必须包含 where 条件")
+	}
+	if err = k.setTable(table.name); err != nil {
+		return err
+	}
+	k.Statement.Filter.Expr, k.Statement.Filter.Conditions, err = parseWhere(where, args)
+	return err
+}
+
+// setValue 解析 set 赋值，只支持常量及参数
+func setValue(expr sqlparser.Expr, args *sqlArgs) (interface{}, error) {
+	switch v := expr.(type) {
+	case *sqlparser.NullVal:
+		return nil, nil
+	case sqlparser.BoolVal:
+		return bool(v), nil
+	case *sqlparser.SQLVal:
+		switch v.Type {
+		case sqlparser.StrVal:
+			return string(v.Val), nil
+		case sqlparser.IntVal:
+			return strconv.ParseInt(string(v.Val), 10, 64)
+		case sqlparser.FloatVal:
+			return strconv.ParseFloat(string(v.Val), 64)
+		case sqlparser.ValArg:
+			// 参数转换为 JSON 类型后作为 patch 的值，如 int 转换为 int64
+			value, err := args.lookup(v)
+			if err != nil {
+				return nil, err
+			}
+			return jsonValue(value)
+		}
+	}
+	return nil, fmt.Errorf("set 赋值仅支持常量: %s", sqlparser.String(expr))
+}
+
+// jsonValue 将参数值转换为 unstructured 支持的 JSON 类型，整数为 int64，小数为 float64，结构体、切片等按 JSON 编码后解析
+func jsonValue(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("set 参数值 %v 无法转换为 JSON: %v", value, err)
+	}
+	var result interface{}
+	if err = utiljson.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("set 参数值 %v 无法转换为 JSON: %v", value, err)
+	}
+	return result, nil
+}
+
+// mergePatch 将 set 赋值转换为 merge patch
+func mergePatch(set []*SetExpr) (string, error) {
+	patch := map[string]interface{}{}
+	for _, s := range set {
+		if err := unstructured.SetNestedField(patch, s.Value, strings.Split(s.Field, ".")...); err != nil {
+			return "", fmt.Errorf("set %s error: %v", s.Field, err)
+		}
+	}
+	return utils.ToJSON(patch), nil
+}
+
+// Exec 执行 update、delete 语句
+// 先按 where 条件查询出匹配的对象，再逐个执行 patch 或 delete 操作。
+// update 使用 merge patch，set 的值为 null 时删除该字段。
+// RowsAffected 为执行成功的对象数量，dest 可传入 nil，不为 nil 时返回每个对象的执行结果
+func (k *Kubectl) Exec(dest *[]SqlResult) *Kubectl {
+	tx := k.getInstance()
+	if tx.Error != nil {
+		return tx
+	}
+	filter := tx.Statement.Filter
+	if filter.Action != SqlActionUpdate && filter.Action != SqlActionDelete {
+		tx.Error = fmt.Errorf("Exec 仅支持 update、delete 语句")
+		return tx
+	}
+	if filter.WhereTree() == nil {
+		tx.Error = fmt.Errorf("update、delete 语句必须包含 where 条件")
+		return tx
+	}
+
+	var patch string
+	if filter.Action == SqlActionUpdate {
+		if patch, tx.Error = mergePatch(filter.Set); tx.Error != nil {
+			return tx
+		}
+	}
+
+	var rows []Row
+	if tx.Error = tx.List(&rows).Error; tx.Error != nil {
+		return tx
+	}
+
+	var results []SqlResult
+	var errs []string
+	var affected int64
+	gvk := tx.Statement.GVK
+	for _, row := range rows {
+		obj := &unstructured.Unstructured{Object: row}
+		result := SqlResult{Namespace: obj.GetNamespace(), Name: obj.GetName(), Action: filter.Action}
+		item := tx.newInstance().GVK(gvk.Group, gvk.Version, gvk.Kind).Namespace(obj.GetNamespace()).Name(obj.GetName())
+		if filter.Action == SqlActionDelete {
+			result.Error = item.Delete().Error
+		} else {
+			var res unstructured.Unstructured
+			result.Error = item.Patch(&res, types.MergePatchType, patch).Error
+		}
+		if result.Error != nil {
+			klog.V(6).Infof("%s %s/%s error: %v", filter.Action, result.Namespace, result.Name, result.Error)
+			errs = append(errs, fmt.Sprintf("%s/%s: %v", result.Namespace, result.Name, result.Error))
+		} else {
+			affected++
+		}
+		results = append(results, result)
+	}
+
+	tx.Statement.RowsAffected = affected
+	if dest != nil {
+		*dest = results
+	}
+	if len(errs) > 0 {
+		tx.Error = fmt.Errorf("%s 失败 %d 个: %s", filter.Action, len(errs), strings.Join(errs, "; "))
+	}
+	return tx
+}
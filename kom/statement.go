@@ -3,6 +3,7 @@ package kom
 import (
 	"context"
 	"io"
+	"regexp"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -49,16 +50,29 @@ type Statement struct {
 	PortForwardLocalPort string                       `json:"port_forward_local_port"`
 	PortForwardPodPort   string                       `json:"port_forward_pod_port"`
 	PortForwardStopCh    chan struct{}                `json:"-"`
+	ClusterErrors        *map[string]error            `json:"-"` // 跨集群查询时，返回各集群的错误
+	Operation            *Operation                   `json:"-"` // 当前执行的操作，回调中使用
+	RecheckInterval      time.Duration                `json:"-"` // WatchQuery 重新计算含 now() 条件的间隔
 }
 type Filter struct {
-	Columns    []string     `json:"columns,omitempty"`
-	Conditions []*Condition `json:"condition,omitempty"` // xx=?
-	Order      string       `json:"order,omitempty"`
-	Limit      int          `json:"limit,omitempty"`
-	Offset     int          `json:"offset,omitempty"`
-	Sql        string       `json:"sql,omitempty"`    // 原始sql
-	Parsed     bool         `json:"parsed,omitempty"` // 是否解析过
-	From       string       `json:"from,omitempty"`   // From TableName
+	Columns     []string     `json:"columns,omitempty"`    // select 查询的字段路径
+	Projection  []*Column    `json:"projection,omitempty"` // select 查询列，为空表示 select *
+	Conditions  []*Condition `json:"condition,omitempty"`  // xx=?
+	Expr        *WhereNode   `json:"expr,omitempty"`       // where 条件表达式树，与Conditions 同源，执行过滤时优先使用
+	GroupBy     []string     `json:"groupBy,omitempty"`    // group by 字段路径
+	Having      *WhereNode   `json:"having,omitempty"`     // having 条件表达式树，作用于分组聚合后的结果行
+	Order       string       `json:"order,omitempty"`
+	Limit       int          `json:"limit,omitempty"`
+	Offset      int          `json:"offset,omitempty"`
+	Sql         string       `json:"sql,omitempty"`         // 原始sql
+	Parsed      bool         `json:"parsed,omitempty"`      // 是否解析过
+	From        string       `json:"from,omitempty"`        // From TableName
+	TableAlias  string       `json:"tableAlias,omitempty"`  // From 表的别名，join 查询中作为字段路径的前缀
+	Joins       []*Join      `json:"joins,omitempty"`       // join 的表，按顺序依次与之前的结果进行连接
+	Action      string       `json:"action,omitempty"`      // sql 语句类型，select、update、delete
+	Set         []*SetExpr   `json:"set,omitempty"`         // update 语句的 set 赋值
+	Clusters    []string     `json:"clusters,omitempty"`    // 跨集群查询的集群ID，如 prod.pod 中的 prod
+	AllClusters bool         `json:"allClusters,omitempty"` // 查询所有集群，如 ALL_CLUSTERS.pod
 }
 type Condition struct {
 	Depth     int
@@ -66,7 +80,111 @@ type Condition struct {
 	Field     string
 	Operator  string
 	Value     interface{} // 通过detectType 赋值为精确类型值，detectType之前都是string
-	ValueType string      // number, string, bool, time
+	ValueType string      // number, string, bool, time，绑定参数按参数的类型赋值，不再探测
+	// From、To between、not between 的范围值，与 Value 一样为精确类型值，Value 为 from and to 的文本
+	From interface{}
+	To   interface{}
+	// Quantifier 数组量词，any 任一值满足即成立，all 全部值满足才成立。
+	// 为空时正向操作符（=、like、in等）按 any 处理，负向操作符（!=、not like、not in等）按 all 处理
+	Quantifier string
+	Pattern    *regexp.Regexp `json:"-"` // regexp、not regexp 编译后的正则表达式
+	Subquery   string         // in、not in 的子查询语句，执行前求值，结果以 []string 赋值给 Value
+	// SubqueryArgs 子查询中使用的参数，按命名参数传递
+	SubqueryArgs map[string]interface{} `json:"-"`
+	// Func 左侧为标量函数时的函数调用，如 lower(metadata.name)，此时 Field 为函数调用的文本
+	Func *FuncCall
+	// refresh 右侧含 now() 时按当前时间重新计算条件值，如 now() - interval 1 hour
+	refresh func(c *Condition) error
+}
+
+// 数组量词
+const (
+	QuantifierAny = "any"
+	QuantifierAll = "all"
+)
+
+// Column select 查询列
+type Column struct {
+	Field string `json:"field"`           // 字段路径，如 metadata.name、spec.containers.image，count(*) 为 *
+	Alias string `json:"alias,omitempty"` // 别名
+	Func  string `json:"func,omitempty"`  // 聚合函数 count、sum、avg、min、max，为空表示普通列
+}
+
+// Name 结果集中的列名，有别名使用别名，否则使用字段路径
+// 聚合列默认列名为 函数名(字段路径)，如 count(*)、sum(spec.replicas)
+func (c *Column) Name() string {
+	if c.Alias != "" {
+		return c.Alias
+	}
+	if c.Func != "" {
+		return AggregateName(c.Func, c.Field)
+	}
+	return c.Field
+}
+
+// IsAggregate 是否聚合查询
+// 设置了 group by，或者查询列中包含聚合函数
+func (f *Filter) IsAggregate() bool {
+	if len(f.GroupBy) > 0 {
+		return true
+	}
+	for _, c := range f.Projection {
+		if c.Func != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Row 查询结果行，key 为列名
+// List 传入 *[]Row 或 *[]map[string]interface{} 时，按 select 的列返回结果，不再转换为完整对象
+type Row map[string]interface{}
+
+// join 类型
+const (
+	JoinInner = "join"
+	JoinLeft  = "left join"
+)
+
+// Join join 查询中被连接的表
+// join 查询的结果中，每张表的对象以表别名（没有别名时为表名）为key存放，
+// 字段路径需要以表别名开头，如 pod.metadata.name、node.metadata.labels.zone
+type Join struct {
+	Type  string                  `json:"type"`            // join 类型，join 或 left join
+	Table string                  `json:"table"`           // 表名
+	Alias string                  `json:"alias,omitempty"` // 表别名
+	GVK   schema.GroupVersionKind `json:"GVK"`             // 表对应的资源类型
+	On    []*JoinOn               `json:"on"`              // 连接条件，多个条件之间为 and 关系
+}
+
+// Name 表在结果中的名称，有别名使用别名，否则使用表名
+func (j *Join) Name() string {
+	if j.Alias != "" {
+		return j.Alias
+	}
+	return j.Table
+}
+
+// JoinOn join 的等值连接条件
+type JoinOn struct {
+	Left  string `json:"left"`  // 之前已连接的表的字段路径，含表名前缀，如 pod.spec.nodeName
+	Right string `json:"right"` // 当前被连接的表的字段路径，不含表名前缀，如 metadata.name
+}
+
+// where 表达式树节点类型
+const (
+	WhereOpAnd       = "AND"
+	WhereOpOr        = "OR"
+	WhereOpNot       = "NOT"
+	WhereOpCondition = "COND"
+)
+
+// WhereNode where 条件表达式树节点
+// AND/OR 节点包含多个子节点，NOT 节点只有一个子节点，COND 为叶子节点，对应一个具体的条件
+type WhereNode struct {
+	Op        string       `json:"op"`
+	Children  []*WhereNode `json:"children,omitempty"`
+	Condition *Condition   `json:"condition,omitempty"`
 }
 
 func (s *Statement) ParseGVKs(gvks []schema.GroupVersionKind, versions ...string) *Statement {
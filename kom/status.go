@@ -91,7 +91,10 @@ func (s *status) IsCRDSupportedByName(name string) bool {
 // apiextensions.k8s.io/v1/customresourcedefinitions false      15
 func (s *status) GetResourceCountSummary(cacheSeconds int) (map[schema.GroupVersionResource]int, error) {
 	d := time.Duration(cacheSeconds) * time.Second
-	return utils.GetOrSetCache(s.kubectl.ClusterCache(), "GetResourceCountSummary", d, func() (map[schema.GroupVersionResource]int, error) {
+	// 任一资源创建、删除后数量都会变化，记录在空 GVR 下
+	defer s.kubectl.TrackCacheKey(schema.GroupVersionResource{}, "", "", "GetResourceCountSummary", d)
+	// 缓存为切片，map 的键为结构体，无法 JSON 编码保存到进程外缓存
+	counts, err := utils.GetOrSetCache(s.kubectl.ClusterCache(), "GetResourceCountSummary", d, func() ([]resourceCount, error) {
 		ctx := s.kubectl.Statement.Context
 
 		config := s.kubectl.RestConfig()
@@ -151,9 +154,28 @@ func (s *status) GetResourceCountSummary(cacheSeconds int) (map[schema.GroupVers
 			}
 		}
 		wg.Wait()
-		return summary, nil
+		counts := make([]resourceCount, 0, len(summary))
+		for gvr, count := range summary {
+			counts = append(counts, resourceCount{GVR: gvr, Count: count})
+		}
+		return counts, nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	summary := make(map[schema.GroupVersionResource]int, len(counts))
+	for _, c := range counts {
+		summary[c.GVR] = c.Count
+	}
+	return summary, nil
 }
+
+// resourceCount 资源数量，用于缓存 GetResourceCountSummary 的结果
+type resourceCount struct {
+	GVR   schema.GroupVersionResource `json:"gvr"`
+	Count int                         `json:"count"`
+}
+
 func countResources(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource, namespaced bool) (int, error) {
 	total := 0
 	var continueToken string
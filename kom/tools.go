@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/duke-git/lancet/v2/slice"
+	"github.com/weibaohui/kom/utils"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -18,6 +19,24 @@ func (u *tools) ClearCache() {
 	u.kubectl.ClusterCache().Clear()
 }
 
+// ClearCacheByPrefix 清除指定前缀的缓存，返回清除的数量
+// 资源的 Get、List 缓存以 CacheKeyPrefix 开头，如 pods//v1/
+func (u *tools) ClearCacheByPrefix(prefix string) int {
+	return u.kubectl.ClusterCache().ClearPrefix(prefix)
+}
+
+// ClearResourceCache 清除当前资源的 Get、List 缓存
+//
+//	kom.DefaultCluster().Resource(&v1.Pod{}).Tools().ClearResourceCache()
+func (u *tools) ClearResourceCache() int {
+	return u.ClearCacheByPrefix(CacheKeyPrefix(u.kubectl.Statement.GVR))
+}
+
+// CacheStats 缓存的命中、未命中、淘汰等统计信息
+func (u *tools) CacheStats() utils.CacheStats {
+	return u.kubectl.ClusterCache().Stats()
+}
+
 // ConvertRuntimeObjectToTypedObject 是一个通用的转换函数，将 runtime.Object 转换为指定的目标类型
 func (u *tools) ConvertRuntimeObjectToTypedObject(obj runtime.Object, target interface{}) error {
 	// 将 obj 断言为 *unstructured.Unstructured 类型
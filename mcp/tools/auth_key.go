@@ -1,5 +1,10 @@
 package tools
 
+import (
+	"context"
+	"fmt"
+)
+
 var authKey string
 
 func SetAuthKey(key string) {
@@ -8,3 +13,21 @@ func SetAuthKey(key string) {
 func AuthKey() string {
 	return authKey
 }
+
+// ContextUser 从 Context 中获取用户，key 为空时使用 SetAuthKey 设置的 key
+func ContextUser(ctx context.Context, key string) string {
+	if key == "" {
+		key = authKey
+	}
+	if ctx == nil || key == "" {
+		return ""
+	}
+	switch v := ctx.Value(key).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
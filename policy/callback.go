@@ -0,0 +1,199 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/mcp/tools"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// CallbackName 策略回调的名称
+const CallbackName = "kom:policy"
+
+// PermissionError 操作被策略拒绝
+type PermissionError struct {
+	Decision Decision
+}
+
+func (e *PermissionError) Error() string {
+	req := e.Decision.Request
+	rule := e.Decision.Rule
+	if rule == "" {
+		rule = "default"
+	}
+	target := req.GVK.Kind
+	switch {
+	case req.Namespace != "" && req.Name != "":
+		target += " " + req.Namespace + "/" + req.Name
+	case req.Namespace != "":
+		target += " " + req.Namespace
+	case req.AllNamespaces:
+		target += " *"
+	case req.Name != "":
+		target += " " + req.Name
+	}
+	return fmt.Sprintf("策略 %s 拒绝用户 [%s] 在集群 %s 上执行 %s %s", rule, req.User, req.Cluster, req.Verb, target)
+}
+
+// IsPermissionDenied 判断错误是否为策略拒绝
+func IsPermissionDenied(err error) bool {
+	var pe *PermissionError
+	return errors.As(err, &pe)
+}
+
+// Option 策略回调配置
+type Option func(*options)
+
+type options struct {
+	authKey  string
+	reporter func(d Decision)
+}
+
+// WithAuthKey 指定从 Context 中获取用户的 key，默认使用 tools.SetAuthKey 设置的 key
+func WithAuthKey(key string) Option {
+	return func(o *options) {
+		o.authKey = key
+	}
+}
+
+// WithReporter 操作被拒绝时调用，测试模式下报告将被拒绝的操作，默认输出警告日志
+func WithReporter(fn func(d Decision)) Option {
+	return func(o *options) {
+		o.reporter = fn
+	}
+}
+
+// Register 为集群的全部处理器注册策略回调，在其他回调之前执行，拒绝时返回 *PermissionError
+// 测试模式下只报告，不拒绝操作。重复注册时替换之前的策略。
+//
+//	p, err := policy.LoadFile("policy.yaml")
+//	err = policy.Register(kom.DefaultCluster(), p)
+func Register(k *kom.Kubectl, p *Policy, opts ...Option) error {
+	if p == nil {
+		return fmt.Errorf("policy 不能为空")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	o := &options{reporter: func(d Decision) {
+		if d.Test {
+			klog.Warningf("policy test mode, would deny: %s", (&PermissionError{Decision: d}).Error())
+		} else {
+			klog.Warningf("%s", (&PermissionError{Decision: d}).Error())
+		}
+	}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	fn := func(tx *kom.Kubectl) error {
+		for _, req := range requests(tx, o.authKey) {
+			d := p.Evaluate(req)
+			if d.Allowed {
+				continue
+			}
+			if o.reporter != nil {
+				o.reporter(d)
+			}
+			if !d.Test {
+				return &PermissionError{Decision: d}
+			}
+		}
+		return nil
+	}
+	for _, name := range k.Callback().ProcessorNames() {
+		proc := k.Callback().Processor(name)
+		var err error
+		if proc.Get(CallbackName) != nil {
+			err = proc.Replace(CallbackName, fn)
+		} else {
+			err = proc.Before("*").Register(CallbackName, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unregister 移除集群的策略回调
+func Unregister(k *kom.Kubectl) {
+	for _, name := range k.Callback().ProcessorNames() {
+		if p := k.Callback().Processor(name); p.Get(CallbackName) != nil {
+			_ = p.Remove(CallbackName)
+		}
+	}
+}
+
+// requests 根据当前操作生成待检查的请求，传入多个命名空间时每个命名空间分别检查
+func requests(k *kom.Kubectl, authKey string) []Request {
+	stmt := k.Statement
+	req := Request{
+		User:      tools.ContextUser(stmt.Context, authKey),
+		Cluster:   k.ID,
+		GVK:       stmt.GVK,
+		Namespace: stmt.Namespace,
+		Name:      stmt.Name,
+	}
+	if stmt.Operation != nil {
+		req.Verb = stmt.Operation.Type
+	}
+	namespaced := stmt.Namespaced
+
+	// 创建、更新时对象信息在 Dest 中
+	if obj, ok := stmt.Dest.(runtime.Object); ok && (req.Verb == "create" || req.Verb == "update") {
+		if req.GVK.Empty() {
+			req.GVK = obj.GetObjectKind().GroupVersionKind()
+		}
+		if accessor, err := meta.Accessor(obj); err == nil {
+			if req.Name == "" {
+				req.Name = accessor.GetName()
+			}
+			if req.Namespace == "" && accessor.GetNamespace() != "" {
+				req.Namespace = accessor.GetNamespace()
+				namespaced = true
+			}
+		}
+	}
+	switch req.Verb {
+	case "exec", "stream-exec", "port-forward", "logs":
+		if req.GVK.Empty() {
+			req.GVK.Version, req.GVK.Kind = "v1", "Pod"
+		}
+		namespaced = true
+	}
+
+	switch {
+	case !namespaced:
+		req.Namespace = ""
+	case req.Verb == "list" || req.Verb == "watch-query" || req.Verb == "watch":
+		if plan := k.PushdownPlan(); plan.ByNamespace {
+			// sql 中的命名空间条件
+			return withNamespaces(req, plan.Namespaces)
+		}
+		if stmt.AllNamespace {
+			req.Namespace = ""
+			req.AllNamespaces = true
+		} else if len(stmt.NamespaceList) > 1 {
+			return withNamespaces(req, stmt.NamespaceList)
+		}
+	}
+	if namespaced && req.Namespace == "" && !stmt.AllNamespace {
+		req.Namespace = metav1.NamespaceDefault
+	}
+	return []Request{req}
+}
+
+func withNamespaces(req Request, namespaces []string) []Request {
+	reqs := make([]Request, 0, len(namespaces))
+	for _, ns := range namespaces {
+		r := req
+		r.Namespace = ns
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
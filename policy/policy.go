@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// 策略模式
+const (
+	ModeEnforce = "enforce" // 拒绝不符合规则的操作
+	ModeTest    = "test"    // 只报告将被拒绝的操作，不拒绝
+)
+
+// 规则效果
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Policy 策略，规则按顺序匹配，使用第一条匹配的规则，都不匹配时使用 Default
+//
+//	mode: enforce
+//	default: allow
+//	rules:
+//	  - name: no-delete-in-kube-system
+//	    effect: deny
+//	    verbs: [delete]
+//	    namespaces: [kube-system]
+//	  - name: exec-team-a
+//	    effect: allow
+//	    verbs: [exec, stream-exec]
+//	    namespaces: ["team-a*"]
+//	  - name: exec-others
+//	    effect: deny
+//	    verbs: [exec, stream-exec]
+//	  - name: readonly-user-x-on-prod
+//	    effect: deny
+//	    users: [x]
+//	    clusters: [prod]
+//	    verbs: [create, update, patch, delete, exec, stream-exec, port-forward]
+type Policy struct {
+	Mode    string `json:"mode,omitempty"`    // enforce（默认）、test
+	Default string `json:"default,omitempty"` // 没有匹配规则时的效果，allow（默认）、deny
+	Rules   []Rule `json:"rules"`
+}
+
+// Rule 规则，各字段为空表示匹配全部，多个值之间为或的关系，字段之间为且的关系
+// 值支持通配符，如 team-a*、*-system，Kind 不区分大小写
+type Rule struct {
+	Name       string   `json:"name"`
+	Effect     string   `json:"effect"`               // allow、deny
+	Users      []string `json:"users,omitempty"`      // 用户，从 Context 中按 AuthKey 获取
+	Clusters   []string `json:"clusters,omitempty"`   // 集群ID
+	Verbs      []string `json:"verbs,omitempty"`      // 操作类型，与回调处理器名称一致，如 get、list、create、delete、exec
+	Groups     []string `json:"groups,omitempty"`     // 资源组，core 组为空字符串
+	Kinds      []string `json:"kinds,omitempty"`      // 资源类型，如 Pod、Deployment
+	Namespaces []string `json:"namespaces,omitempty"` // 命名空间，集群级资源及全部命名空间查询时为空字符串；全部命名空间查询包含任一命名空间，匹配全部 deny 规则
+	Names      []string `json:"names,omitempty"`      // 资源名称
+}
+
+// Request 待检查的操作
+type Request struct {
+	User      string                  `json:"user,omitempty"`
+	Cluster   string                  `json:"cluster"`
+	Verb      string                  `json:"verb"`
+	GVK       schema.GroupVersionKind `json:"gvk"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Name      string                  `json:"name,omitempty"`
+	// AllNamespaces 是否为全部命名空间的 list、watch，此时 Namespace 为空
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+}
+
+// Decision 检查结果
+type Decision struct {
+	Request Request `json:"request"`
+	Allowed bool    `json:"allowed"`
+	Rule    string  `json:"rule,omitempty"` // 匹配的规则名称，使用默认效果时为空
+	Test    bool    `json:"test,omitempty"` // 是否为测试模式，测试模式下不拒绝操作
+}
+
+// Load 解析 YAML 或 JSON 格式的策略
+func Load(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("解析策略失败: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadFile 从文件加载策略
+func LoadFile(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data)
+}
+
+// Validate 检查策略的模式、效果及通配符是否合法
+func (p *Policy) Validate() error {
+	switch p.Mode {
+	case "", ModeEnforce, ModeTest:
+	default:
+		return fmt.Errorf("策略模式 %s 不合法，只支持 %s、%s", p.Mode, ModeEnforce, ModeTest)
+	}
+	switch p.Default {
+	case "", EffectAllow, EffectDeny:
+	default:
+		return fmt.Errorf("策略默认效果 %s 不合法，只支持 %s、%s", p.Default, EffectAllow, EffectDeny)
+	}
+	for i, r := range p.Rules {
+		if r.Effect != EffectAllow && r.Effect != EffectDeny {
+			return fmt.Errorf("规则 %d(%s) 的效果 %s 不合法，只支持 %s、%s", i, r.Name, r.Effect, EffectAllow, EffectDeny)
+		}
+		for _, patterns := range [][]string{r.Users, r.Clusters, r.Verbs, r.Groups, r.Kinds, r.Namespaces, r.Names} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("规则 %d(%s) 的通配符 %s 不合法: %w", i, r.Name, pattern, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate 按规则顺序检查操作
+func (p *Policy) Evaluate(req Request) Decision {
+	d := Decision{Request: req, Allowed: p.Default != EffectDeny, Test: p.Mode == ModeTest}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.matches(req) {
+			d.Allowed = r.Effect == EffectAllow
+			d.Rule = r.Name
+			break
+		}
+	}
+	return d
+}
+
+func (r *Rule) matches(req Request) bool {
+	return matchAny(r.Users, req.User, false) &&
+		matchAny(r.Clusters, req.Cluster, false) &&
+		matchAny(r.Verbs, req.Verb, false) &&
+		matchAny(r.Groups, req.GVK.Group, false) &&
+		matchAny(r.Kinds, req.GVK.Kind, true) &&
+		r.matchNamespace(req) &&
+		matchAny(r.Names, req.Name, false)
+}
+
+// matchNamespace 全部命名空间的查询会读取每个命名空间，限定命名空间的 deny 规则也需要匹配，否则可以绕过
+func (r *Rule) matchNamespace(req Request) bool {
+	if req.AllNamespaces && r.Effect == EffectDeny {
+		return true
+	}
+	return matchAny(r.Namespaces, req.Namespace, false)
+}
+
+// matchAny 值匹配任一通配符，通配符为空时匹配全部
+func matchAny(patterns []string, value string, ignoreCase bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if ignoreCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -1,34 +1,171 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/dgraph-io/ristretto/v2"
 	"k8s.io/klog/v2"
 )
 
-func GetOrSetCache[T any](cache *ristretto.Cache[string, any], cacheKey string, ttl time.Duration, queryFunc func() (T, error)) (T, error) {
+// Cache 缓存接口
+// 默认使用 ristretto 进程内缓存，也可以使用 Redis 等进程外缓存，在多个实例之间共享
+type Cache interface {
+	// Get 获取缓存，进程外缓存返回 EncodedValue，由调用方解码
+	Get(key string) (any, bool)
+	// Set 设置缓存，ttl 为过期时间
+	Set(key string, value any, ttl time.Duration) bool
+	// Del 删除缓存
+	Del(key string)
+	// Clear 清空缓存
+	Clear()
+	// ClearPrefix 删除指定前缀的缓存，返回删除的数量
+	ClearPrefix(prefix string) int
+	// Stats 命中、未命中、淘汰等统计信息
+	Stats() CacheStats
+	// Close 释放资源
+	Close()
+}
+
+// TaggedCache 支持按标签清除缓存，标签保存在缓存后端中
+// 多个实例共享进程外缓存时，写入资源的实例据此清除其他实例写入的缓存
+type TaggedCache interface {
+	Cache
+	// Tag 将缓存键记录到标签下，ttl 为缓存键的过期时间
+	Tag(tag string, key string, ttl time.Duration)
+	// ClearTag 删除标签下记录的缓存键，返回删除的数量
+	ClearTag(tag string) int
+}
+
+// CacheStats 缓存统计信息
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`      // 命中次数
+	Misses    uint64 `json:"misses"`    // 未命中次数
+	Sets      uint64 `json:"sets"`      // 写入次数
+	Evictions uint64 `json:"evictions"` // 因容量、过期被淘汰的数量
+	Keys      int    `json:"keys"`      // 当前缓存键数量，进程外缓存不统计
+}
+
+// HitRatio 命中率
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// EncodedValue 进程外缓存中保存的 JSON 编码值，读取时解码为调用方需要的类型
+type EncodedValue []byte
+
+// decodeCacheValue 将缓存值转换为指定类型
+func decodeCacheValue[T any](v any) (T, bool) {
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	var t T
+	raw, ok := v.(EncodedValue)
+	if !ok {
+		return t, false
+	}
+	if err := json.Unmarshal(raw, &t); err != nil {
+		klog.V(6).Infof("decode cache value error %v", err)
+		return t, false
+	}
+	return t, true
+}
+
+// cacheCall 正在执行的缓存查询，相同缓存键的并发调用方共享结果
+type cacheCall struct {
+	done    chan struct{}
+	val     any
+	err     error
+	waiters int                // 等待结果的调用方数量
+	cancel  context.CancelFunc // 所有调用方都已返回时取消查询
+}
+
+// cacheCalls 正在执行的查询，键中带有缓存实例地址，按集群区分
+var (
+	cacheCalls     = map[string]*cacheCall{}
+	cacheCallsLock sync.Mutex
+)
+
+func GetOrSetCache[T any](cache Cache, cacheKey string, ttl time.Duration, queryFunc func() (T, error)) (T, error) {
+	return GetOrSetCacheWithContext(context.Background(), cache, cacheKey, ttl, func(context.Context) (T, error) {
+		return queryFunc()
+	})
+}
+
+// GetOrSetCacheWithContext 带 Context 的缓存查询
+// 缓存未命中时，同一集群相同缓存键的并发查询只执行一次，所有调用方共享结果。
+// 查询使用独立的 Context 执行，一个调用方取消不会导致其他调用方失败；
+// 调用方的 Context 取消或超时时立即返回 ctx.Err()，所有调用方都已返回时取消查询。
+func GetOrSetCacheWithContext[T any](ctx context.Context, cache Cache, cacheKey string, ttl time.Duration, queryFunc func(ctx context.Context) (T, error)) (T, error) {
 	var zero T
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	// 如果未设置 TTL 参数，说明不需要缓存，则直接执行查询方法
-	if ttl <= 0 {
-		return queryFunc()
+	if ttl <= 0 || cache == nil {
+		return queryFunc(ctx)
 	}
 	// 检查缓存是否命中
 	if v, found := cache.Get(cacheKey); found {
-		klog.V(8).Infof("cache hit cacheKey= %s", cacheKey)
-		return v.(T), nil
+		if result, ok := decodeCacheValue[T](v); ok {
+			klog.V(8).Infof("cache hit cacheKey= %s", cacheKey)
+			return result, nil
+		}
 	}
 
-	// 缓存未命中，执行查询方法
-	result, err := queryFunc()
-	if err != nil {
-		return zero, err
+	// 缓存未命中，执行查询方法，相同缓存键的并发查询合并为一次
+	key := fmt.Sprintf("%p/%s", cache, cacheKey)
+	cacheCallsLock.Lock()
+	c, shared := cacheCalls[key]
+	if !shared {
+		queryCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &cacheCall{done: make(chan struct{}), cancel: cancel}
+		cacheCalls[key] = c
+		go func() {
+			defer cancel()
+			result, err := queryFunc(queryCtx)
+			if err == nil {
+				// 设置缓存
+				cache.Set(cacheKey, result, ttl)
+			}
+			c.val, c.err = result, err
+			cacheCallsLock.Lock()
+			if cacheCalls[key] == c {
+				delete(cacheCalls, key)
+			}
+			cacheCallsLock.Unlock()
+			close(c.done)
+		}()
+	} else {
+		klog.V(8).Infof("cache query shared cacheKey= %s", cacheKey)
 	}
+	c.waiters++
+	cacheCallsLock.Unlock()
 
-	// 设置缓存并返回结果
-	cache.SetWithTTL(cacheKey, result, 100, ttl)
-	cache.Wait()
-
-	return result, nil
+	select {
+	case <-ctx.Done():
+		cacheCallsLock.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// 没有调用方等待结果，取消查询，之后的调用方重新查询
+			c.cancel()
+			if cacheCalls[key] == c {
+				delete(cacheCalls, key)
+			}
+		}
+		cacheCallsLock.Unlock()
+		return zero, ctx.Err()
+	case <-c.done:
+		if c.err != nil {
+			return zero, c.err
+		}
+		result, _ := c.val.(T)
+		return result, nil
+	}
 }
@@ -0,0 +1,403 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// RedisCacheOptions Redis 缓存配置
+type RedisCacheOptions struct {
+	Password    string        // 密码
+	DB          int           // 数据库编号
+	Prefix      string        // 键前缀，必填，多个集群共用一个 Redis 时用于区分，如 kom:cluster-a:
+	DialTimeout time.Duration // 连接超时，默认 3 秒
+	IOTimeout   time.Duration // 读写超时，默认 3 秒
+	PoolSize    int           // 空闲连接数，默认 8
+}
+
+// RedisCache 基于 Redis 协议（RESP）的进程外缓存
+// 可用于 Redis 及兼容 Redis 协议的服务，多个实例共享同一份缓存。值使用 JSON 编码保存，读取时返回 EncodedValue
+// 标签使用 Redis 集合保存，多个实例写入资源后都能清除相关缓存
+type RedisCache struct {
+	addr   string
+	opts   RedisCacheOptions
+	pool   chan *redisConn
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	sets   atomic.Uint64
+	closed atomic.Bool
+}
+
+// redisError Redis 返回的错误
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisTagPrefix 标签集合的键前缀，位于 Prefix 之后
+const redisTagPrefix = "tag:"
+
+// NewRedisCache 创建 Redis 缓存，并检查连接是否可用
+// Prefix 不能为空，Clear、ClearPrefix 只删除 Prefix 下的键，避免清空 Redis 中的其他数据
+func NewRedisCache(addr string, opts RedisCacheOptions) (*RedisCache, error) {
+	if opts.Prefix == "" {
+		return nil, errors.New("redis cache prefix is required")
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 3 * time.Second
+	}
+	if opts.IOTimeout <= 0 {
+		opts.IOTimeout = 3 * time.Second
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 8
+	}
+	c := &RedisCache{
+		addr: addr,
+		opts: opts,
+		pool: make(chan *redisConn, opts.PoolSize),
+	}
+	if _, err := c.do("PING"); err != nil {
+		return nil, fmt.Errorf("connect redis %s: %w", addr, err)
+	}
+	return c, nil
+}
+
+func (c *RedisCache) Get(key string) (any, bool) {
+	reply, err := c.do("GET", c.opts.Prefix+key)
+	if err != nil {
+		klog.V(6).Infof("redis cache get %s error %v", key, err)
+	}
+	data, ok := reply.([]byte)
+	if err != nil || !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return EncodedValue(data), true
+}
+
+func (c *RedisCache) Set(key string, value any, ttl time.Duration) bool {
+	data, err := json.Marshal(value)
+	if err != nil {
+		klog.Warningf("redis cache encode %s error %v", key, err)
+		return false
+	}
+	ms := ttl.Milliseconds()
+	if ms <= 0 {
+		ms = 1
+	}
+	if _, err = c.do("SET", c.opts.Prefix+key, string(data), "PX", strconv.FormatInt(ms, 10)); err != nil {
+		klog.Warningf("redis cache set %s error %v", key, err)
+		return false
+	}
+	c.sets.Add(1)
+	return true
+}
+
+func (c *RedisCache) Del(key string) {
+	if _, err := c.do("DEL", c.opts.Prefix+key); err != nil {
+		klog.V(6).Infof("redis cache del %s error %v", key, err)
+	}
+}
+
+// Clear 清空缓存，只删除 Prefix 下的键
+func (c *RedisCache) Clear() {
+	c.ClearPrefix("")
+}
+
+func (c *RedisCache) ClearPrefix(prefix string) int {
+	count := 0
+	err := c.scan(prefix, func(keys []string) error {
+		args := append([]string{"DEL"}, keys...)
+		reply, err := c.do(args...)
+		if n, ok := reply.(int64); ok {
+			count += int(n)
+		}
+		return err
+	})
+	if err != nil {
+		klog.V(6).Infof("redis cache clear prefix %s error %v", prefix, err)
+	}
+	return count
+}
+
+// Stats 命中、未命中、写入次数为当前实例的统计，淘汰数量为 Redis 服务端的 evicted_keys 与 expired_keys 之和
+// 统计键数量需要遍历整个键空间，不统计 Keys
+func (c *RedisCache) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Sets:   c.sets.Load(),
+	}
+	if reply, err := c.do("INFO", "stats"); err == nil {
+		if data, ok := reply.([]byte); ok {
+			for _, line := range strings.Split(string(data), "\r\n") {
+				name, value, found := strings.Cut(line, ":")
+				if !found || (name != "evicted_keys" && name != "expired_keys") {
+					continue
+				}
+				if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+					stats.Evictions += n
+				}
+			}
+		}
+	}
+	return stats
+}
+
+// Tag 将缓存键加入标签集合，集合的过期时间不短于其中缓存键的过期时间
+func (c *RedisCache) Tag(tag string, key string, ttl time.Duration) {
+	setKey := c.opts.Prefix + redisTagPrefix + tag
+	if _, err := c.do("SADD", setKey, c.opts.Prefix+key); err != nil {
+		klog.V(6).Infof("redis cache tag %s error %v", tag, err)
+		return
+	}
+	ms := ttl.Milliseconds()
+	if ms <= 0 {
+		ms = 1
+	}
+	reply, err := c.do("PTTL", setKey)
+	if n, ok := reply.(int64); err == nil && ok && n >= ms {
+		return
+	}
+	if _, err = c.do("PEXPIRE", setKey, strconv.FormatInt(ms, 10)); err != nil {
+		klog.V(6).Infof("redis cache tag %s expire error %v", tag, err)
+	}
+}
+
+// ClearTag 删除标签集合中的缓存键
+// 先将集合改名再读取，清除期间新加入的缓存键记录到新的集合中，不会丢失
+func (c *RedisCache) ClearTag(tag string) int {
+	setKey := c.opts.Prefix + redisTagPrefix + tag
+	tmpKey := fmt.Sprintf("%s:clearing:%d", setKey, time.Now().UnixNano())
+	if _, err := c.do("RENAME", setKey, tmpKey); err != nil {
+		var re redisError
+		if !errors.As(err, &re) {
+			klog.V(6).Infof("redis cache clear tag %s error %v", tag, err)
+		}
+		// 集合不存在
+		return 0
+	}
+	reply, err := c.do("SMEMBERS", tmpKey)
+	if err != nil {
+		klog.V(6).Infof("redis cache clear tag %s error %v", tag, err)
+	}
+	items, _ := reply.([]interface{})
+	args := []string{"DEL", tmpKey}
+	for _, item := range items {
+		if key, ok := item.([]byte); ok {
+			args = append(args, string(key))
+		}
+	}
+	reply, err = c.do(args...)
+	if err != nil {
+		klog.V(6).Infof("redis cache clear tag %s error %v", tag, err)
+		return 0
+	}
+	n, _ := reply.(int64)
+	// 不计入标签集合本身
+	return max(int(n)-1, 0)
+}
+
+func (c *RedisCache) Close() {
+	if c.closed.Swap(true) {
+		return
+	}
+	for {
+		select {
+		case conn := <-c.pool:
+			_ = conn.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// scan 遍历 Prefix+prefix 开头的键
+func (c *RedisCache) scan(prefix string, fn func(keys []string) error) error {
+	pattern := escapeRedisPattern(c.opts.Prefix+prefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", pattern, "COUNT", "500")
+		if err != nil {
+			return err
+		}
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) != 2 {
+			return fmt.Errorf("unexpected scan reply %v", reply)
+		}
+		next, _ := arr[0].([]byte)
+		items, _ := arr[1].([]interface{})
+		var keys []string
+		for _, item := range items {
+			if key, ok := item.([]byte); ok {
+				keys = append(keys, string(key))
+			}
+		}
+		if len(keys) > 0 {
+			if err = fn(keys); err != nil {
+				return err
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// do 执行命令，网络错误时关闭连接，Redis 返回的错误不影响连接复用
+func (c *RedisCache) do(args ...string) (interface{}, error) {
+	if c.closed.Load() {
+		return nil, errors.New("redis cache is closed")
+	}
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(c.opts.IOTimeout, args...)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		_ = conn.conn.Close()
+		return nil, err
+	}
+	c.release(conn)
+	return reply, err
+}
+
+// conn 从连接池获取连接，没有空闲连接时新建
+func (c *RedisCache) conn() (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+	nc, err := net.DialTimeout("tcp", c.addr, c.opts.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: nc, r: bufio.NewReader(nc)}
+	if c.opts.Password != "" {
+		if _, err = conn.do(c.opts.IOTimeout, "AUTH", c.opts.Password); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB > 0 {
+		if _, err = conn.do(c.opts.IOTimeout, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// release 归还连接，连接池已满或已关闭时关闭连接
+func (c *RedisCache) release(conn *redisConn) {
+	if c.closed.Load() {
+		_ = conn.conn.Close()
+		return
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		_ = conn.conn.Close()
+	}
+}
+
+func (rc *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	_ = rc.conn.SetDeadline(time.Now().Add(timeout))
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		sb.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+	}
+	if _, err := io.WriteString(rc.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(rc.r)
+}
+
+// readRedisReply 读取 RESP 回复
+// 简单字符串返回 string，整数返回 int64，批量字符串返回 []byte（不存在时为 nil），数组返回 []interface{}
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = readRedisReply(r); err != nil {
+				var re redisError
+				if !errors.As(err, &re) {
+					return nil, err
+				}
+				arr[i] = re
+			}
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("unknown redis reply %q", line)
+}
+
+// escapeRedisPattern 转义 SCAN MATCH 中的通配符
+func escapeRedisPattern(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// ristrettoPruneInterval 每写入该数量的缓存，清理一次已过期的缓存键记录
+const ristrettoPruneInterval = 1024
+
+// RistrettoCache 基于 ristretto 的进程内缓存，集群默认使用
+// ristretto 不支持遍历缓存键，这里记录写入的键及过期时间，用于按前缀清除
+type RistrettoCache struct {
+	cache     *ristretto.Cache[string, any]
+	mu        sync.Mutex
+	keys      map[string]time.Time
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	sets      atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// NewRistrettoCache 创建 ristretto 缓存，cfg 中的 OnEvict 仍会被调用
+func NewRistrettoCache(cfg *ristretto.Config[string, any]) (*RistrettoCache, error) {
+	c := &RistrettoCache{keys: map[string]time.Time{}}
+	config := *cfg
+	onEvict := cfg.OnEvict
+	config.OnEvict = func(item *ristretto.Item[any]) {
+		c.evictions.Add(1)
+		if onEvict != nil {
+			onEvict(item)
+		}
+	}
+	cache, err := ristretto.NewCache(&config)
+	if err != nil {
+		return nil, err
+	}
+	c.cache = cache
+	return c, nil
+}
+
+func (c *RistrettoCache) Get(key string) (any, bool) {
+	v, found := c.cache.Get(key)
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return v, found
+}
+
+func (c *RistrettoCache) Set(key string, value any, ttl time.Duration) bool {
+	ok := c.cache.SetWithTTL(key, value, 100, ttl)
+	c.cache.Wait()
+	if !ok {
+		return false
+	}
+	sets := c.sets.Add(1)
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sets%ristrettoPruneInterval == 0 {
+		c.pruneLocked(now)
+	}
+	c.keys[key] = now.Add(ttl)
+	return true
+}
+
+func (c *RistrettoCache) Del(key string) {
+	c.cache.Del(key)
+	c.mu.Lock()
+	delete(c.keys, key)
+	c.mu.Unlock()
+}
+
+func (c *RistrettoCache) Clear() {
+	c.cache.Clear()
+	c.mu.Lock()
+	c.keys = map[string]time.Time{}
+	c.mu.Unlock()
+}
+
+func (c *RistrettoCache) ClearPrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneLocked(time.Now())
+	count := 0
+	for key := range c.keys {
+		if strings.HasPrefix(key, prefix) {
+			c.cache.Del(key)
+			delete(c.keys, key)
+			count++
+		}
+	}
+	return count
+}
+
+func (c *RistrettoCache) Stats() CacheStats {
+	c.mu.Lock()
+	c.pruneLocked(time.Now())
+	keys := len(c.keys)
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Evictions: c.evictions.Load(),
+		Keys:      keys,
+	}
+}
+
+func (c *RistrettoCache) Close() {
+	c.cache.Close()
+}
+
+// Ristretto 底层的 ristretto 缓存
+func (c *RistrettoCache) Ristretto() *ristretto.Cache[string, any] {
+	return c.cache
+}
+
+// pruneLocked 清理已过期的缓存键记录，需持有锁
+func (c *RistrettoCache) pruneLocked(now time.Time) {
+	for key, expire := range c.keys {
+		if now.After(expire) {
+			delete(c.keys, key)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+func newTestCache(t *testing.T) Cache {
+	cache, err := NewRistrettoCache(&ristretto.Config[string, any]{
+		NumCounters: 1e4,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+	})
+	if err != nil {
+		t.Fatalf("NewRistrettoCache error %v", err)
+	}
+	t.Cleanup(cache.Close)
+	return cache
+}
+
+func TestGetOrSetCacheWithContextShared(t *testing.T) {
+	cache := newTestCache(t)
+	var calls atomic.Int32
+	query := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return "pods", nil
+	}
+
+	// 相同缓存键的并发查询只执行一次
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := GetOrSetCacheWithContext(context.Background(), cache, "shared", time.Minute, query)
+			if err != nil || v != "pods" {
+				t.Errorf("GetOrSetCacheWithContext = %q, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("queryFunc called %d times, want 1", n)
+	}
+
+	// 之后从缓存获取
+	if v, err := GetOrSetCacheWithContext(context.Background(), cache, "shared", time.Minute, query); err != nil || v != "pods" {
+		t.Fatalf("GetOrSetCacheWithContext = %q, %v", v, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("queryFunc called %d times after cache hit, want 1", n)
+	}
+}
+
+func TestGetOrSetCacheWithContextCancel(t *testing.T) {
+	cache := newTestCache(t)
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	query := func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return "", ctx.Err()
+	}
+
+	// 一个调用方超时返回，另一个调用方仍在等待时不取消查询
+	ctx1, cancel1 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := GetOrSetCacheWithContext(ctx1, cache, "cancel", time.Minute, query)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := GetOrSetCacheWithContext(ctx2, cache, "cancel", time.Minute, query)
+		errs <- err
+	}()
+
+	if err := <-errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("first caller error %v, want deadline exceeded", err)
+	}
+	select {
+	case <-canceled:
+		t.Fatalf("query canceled while another caller is waiting")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 所有调用方都返回后取消查询
+	cancel2()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("second caller error %v, want canceled", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("query not canceled after all callers returned")
+	}
+}
@@ -3,32 +3,52 @@ package utils
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // 定义字符串的类型
 const (
-	TypeNumber  = "number"
-	TypeTime    = "time"
-	TypeString  = "string"
-	TypeBoolean = "boolean"
+	TypeNumber   = "number"
+	TypeTime     = "time"
+	TypeString   = "string"
+	TypeBoolean  = "boolean"
+	TypeQuantity = "quantity" // k8s Quantity，如 500m、2Gi
+	TypeDuration = "duration" // 时间间隔，如 30s、1h30m
 )
 
-// DetectType 探测字符串的类型（数字、时间、字符串）
+// DetectType 探测字符串的类型（布尔、数字、时间、Quantity、Duration、字符串）
+// 5m 既可以是 Quantity 也可以是 Duration，优先识别为 Quantity
 func DetectType(value interface{}) (string, interface{}) {
+	str := fmt.Sprintf("%v", value)
 
-	if boolean, err := strconv.ParseBool(fmt.Sprintf("%v", value)); err == nil {
-		return TypeBoolean, boolean
+	// 只识别 true/false，strconv.ParseBool 会将 1、0 识别为布尔值
+	switch strings.ToLower(str) {
+	case "true":
+		return TypeBoolean, true
+	case "false":
+		return TypeBoolean, false
 	}
 
 	// 1. 尝试解析为整数或浮点数
-	if num, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64); err == nil {
+	if num, err := strconv.ParseFloat(str, 64); err == nil {
 		return TypeNumber, num
 	}
 
-	if t, err := ParseTime(fmt.Sprintf("%v", value)); err == nil {
+	if t, err := ParseTime(str); err == nil {
 		return TypeTime, t
 	}
 
-	// 3. 默认返回字符串类型
+	if q, err := resource.ParseQuantity(str); err == nil {
+		return TypeQuantity, q
+	}
+
+	if d, err := time.ParseDuration(str); err == nil {
+		return TypeDuration, d
+	}
+
+	// 默认返回字符串类型
 	return TypeString, value
 }
@@ -8,11 +8,18 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+// SortByCreationTime 按创建时间倒序排列，创建时间相同时按命名空间、名称排序，保证结果稳定
 func SortByCreationTime(items []*unstructured.Unstructured) []*unstructured.Unstructured {
-	sort.Slice(items, func(i, j int) bool {
+	sort.SliceStable(items, func(i, j int) bool {
 		ti := items[i].GetCreationTimestamp()
 		tj := items[j].GetCreationTimestamp()
-		return ti.After(tj.Time)
+		if !ti.Equal(&tj) {
+			return ti.After(tj.Time)
+		}
+		if items[i].GetNamespace() != items[j].GetNamespace() {
+			return items[i].GetNamespace() < items[j].GetNamespace()
+		}
+		return items[i].GetName() < items[j].GetName()
 	})
 	return items
 }